@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ArrConfig - прием webhooks Sonarr/Radarr о новых импортированных файлах
+type ArrConfig struct {
+	Enabled bool `json:"enabled"`
+	// Token - ключ, который Sonarr/Radarr передают в параметре ?token= или как пароль Basic авторизации
+	Token string `json:"token"`
+	// PathMap - переписывание путей, если *arr видит медиатеку под другим путем
+	PathMap []PathMapping `json:"pathMap"`
+	// Profile - профиль конвертации для импортированных файлов, пусто - профиль по умолчанию
+	Profile string `json:"profile"`
+	// Codecs - в очередь попадают только файлы с этими аудиокодеками (по ffprobe),
+	// пусто - любые, например ["dts"] ограничивает прием файлами с DTS
+	Codecs []string `json:"codecs"`
+}
+
+// AcceptsCodec возвращает true, если аудиокодек подходит для автоматической конвертации
+func (a *ArrConfig) AcceptsCodec(codec string) bool {
+	if len(a.Codecs) == 0 {
+		return true
+	}
+	for _, c := range a.Codecs {
+		if strings.EqualFold(c, codec) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateArr заполняет значения по умолчанию и проверяет прием webhooks *arr
+func (c *Config) validateArr() error {
+	if !c.Arr.Enabled {
+		return nil
+	}
+	if c.Arr.Token == "" {
+		return fmt.Errorf("arr.token: нужно задать ключ для приема webhooks")
+	}
+	if c.Arr.Profile != "" {
+		if _, err := c.Profile(c.Arr.Profile); err != nil {
+			return fmt.Errorf("arr.profile: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config - настройки приложения, загружаемые из JSON файла
+type Config struct {
+	Schedule ScheduleConfig `json:"schedule"`
+	Throttle ThrottleConfig `json:"throttle"`
+	Disk     DiskConfig     `json:"disk"`
+	Output   OutputConfig   `json:"output"`
+
+	// MediaRoots - корневые директории медиатек
+	MediaRoots []string         `json:"mediaRoots"`
+	Scan       ScanConfig       `json:"scan"`
+	Search     SearchConfig     `json:"search"`
+	Quarantine QuarantineConfig `json:"quarantine"`
+	Sidecars   SidecarConfig    `json:"sidecars"`
+	Notifiers  []NotifierConfig `json:"notifiers"`
+	Webhooks   []WebhookConfig  `json:"webhooks"`
+	Arr        ArrConfig        `json:"arr"`
+	MQTT       MQTTConfig       `json:"mqtt"`
+	Email      EmailConfig      `json:"email"`
+	History    HistoryConfig    `json:"history"`
+
+	// AdminToken - ключ для служебных запросов /api/history/archive и /api/bundle, передается
+	// в параметре ?token= или как пароль Basic авторизации. Пусто - запросы отклоняются
+	AdminToken string `json:"adminToken"`
+
+	Profiles       []ProfileConfig `json:"profiles"`
+	DefaultProfile string          `json:"defaultProfile"`
+}
+
+// ScheduleConfig - окна времени, в которые разрешен запуск конвертаций
+type ScheduleConfig struct {
+	Enabled bool             `json:"enabled"`
+	Windows []ScheduleWindow `json:"windows"`
+	// OnWindowClose - что делать с текущей задачей при закрытии окна: finish или suspend
+	OnWindowClose string `json:"onWindowClose"`
+}
+
+// ScheduleWindow - одно окно обработки
+type ScheduleWindow struct {
+	// Days - дни недели (mon..sun), а также weekdays, weekend или all
+	Days []string `json:"days"`
+	// Start и End в формате HH:MM, окно может переходить через полночь.
+	// Пустые значения или Start == End означают весь день
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// ThrottleConfig - ограничение нагрузки, которую создает FFmpeg
+type ThrottleConfig struct {
+	// Nice - приоритет CPU процесса FFmpeg (0..19, больше - ниже приоритет)
+	Nice int `json:"nice"`
+	// IOClass - класс приоритета ввода-вывода: best-effort или idle, пусто - не менять
+	IOClass string `json:"ioClass"`
+	// IOPriority - уровень внутри класса best-effort (0..7, больше - ниже приоритет)
+	IOPriority int `json:"ioPriority"`
+	// Threads - ограничение числа потоков FFmpeg, 0 - по умолчанию
+	Threads int `json:"threads"`
+	// MaxLoadAverage - не запускать новые задачи при loadavg за 1 минуту выше порога, 0 - без проверки
+	MaxLoadAverage float64 `json:"maxLoadAverage"`
+	// MaxIOWait - не запускать новые задачи при доле iowait CPU выше порога в процентах, 0 - без проверки
+	MaxIOWait float64 `json:"maxIOWait"`
+}
+
+// DiskConfig - проверка свободного места перед запуском конвертации
+type DiskConfig struct {
+	// SafetyMarginPercent - запас сверх оценки размера выходного файла в процентах
+	SafetyMarginPercent float64 `json:"safetyMarginPercent"`
+	// MinFreeMB - сколько места должно остаться на диске после конвертации
+	MinFreeMB int64 `json:"minFreeMB"`
+	// OnInsufficient - что делать при нехватке места: hold (ждать) или fail
+	OnInsufficient string `json:"onInsufficient"`
+}
+
+// OutputConfig - куда FFmpeg пишет результат до переноса на итоговое место
+type OutputConfig struct {
+	// ScratchDir - директория на быстром диске для временных файлов,
+	// пусто - скрытый файл рядом с итоговым
+	ScratchDir string `json:"scratchDir"`
+}
+
+// ScanConfig - сканирование аудиодорожек медиатеки
+type ScanConfig struct {
+	// Workers - сколько процессов ffprobe запускается одновременно
+	Workers int `json:"workers"`
+	// CheckInode - считать файл измененным и при смене inode (замена файла с тем же размером и временем)
+	CheckInode bool `json:"checkInode"`
+}
+
+// SearchConfig - поиск файлов по имени
+type SearchConfig struct {
+	// MaxResults - после стольких найденных файлов поиск останавливается
+	MaxResults int `json:"maxResults"`
+	// ExcludeDirs - пропускаемые директории: шаблоны имени (@eaDir, .*) или абсолютные пути
+	ExcludeDirs []string `json:"excludeDirs"`
+}
+
+// Excluded проверяет, нужно ли пропустить директорию при поиске
+func (c SearchConfig) Excluded(dir string) bool {
+	for _, exclude := range c.ExcludeDirs {
+		if filepath.IsAbs(exclude) {
+			if dir == exclude || strings.HasPrefix(dir, exclude+string(filepath.Separator)) {
+				return true
+			}
+			continue
+		}
+		if matched, _ := filepath.Match(exclude, filepath.Base(dir)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// QuarantineConfig - карантин для исходных файлов после конвертации
+type QuarantineConfig struct {
+	// Dir - директория карантина, структура медиатек в ней повторяется
+	Dir string `json:"dir"`
+	// PurgeAfterDays - через сколько дней удалять файлы из карантина, 0 - не удалять
+	PurgeAfterDays int `json:"purgeAfterDays"`
+}
+
+// SidecarConfig - сопутствующие файлы (субтитры, nfo, постеры) с тем же именем, что и исходник
+type SidecarConfig struct {
+	// Mode - rename (переименовать под результат), copy (скопировать) или off
+	Mode string `json:"mode"`
+	// Extensions - расширения сопутствующих файлов без точки
+	Extensions []string `json:"extensions"`
+}
+
+const (
+	OnWindowCloseFinish  = "finish"
+	OnWindowCloseSuspend = "suspend"
+
+	IOClassBestEffort = "best-effort"
+	IOClassIdle       = "idle"
+
+	OnInsufficientHold = "hold"
+	OnInsufficientFail = "fail"
+
+	SidecarRename = "rename"
+	SidecarCopy   = "copy"
+	SidecarOff    = "off"
+)
+
+// Path возвращает путь к файлу конфигурации
+func Path() string {
+	if envPath := os.Getenv("CONFIG_PATH"); envPath != "" {
+		return envPath
+	}
+	return filepath.Join("./data", "config.json")
+}
+
+// Default возвращает конфигурацию по умолчанию. Профили заполняются в Validate,
+// чтобы профили из файла не смешивались со встроенным
+func Default() *Config {
+	return &Config{
+		MediaRoots: []string{"/media"},
+		Schedule: ScheduleConfig{
+			OnWindowClose: OnWindowCloseFinish,
+		},
+		Sidecars: SidecarConfig{
+			Mode: SidecarRename,
+		},
+		Disk: DiskConfig{
+			SafetyMarginPercent: 10,
+			MinFreeMB:           1024,
+			OnInsufficient:      OnInsufficientHold,
+		},
+		MQTT:  defaultMQTT(),
+		Email: defaultEmail(),
+	}
+}
+
+// Load загружает конфигурацию из файла, отсутствующий файл означает настройки по умолчанию
+func Load() (*Config, error) {
+	cfg := Default()
+	path := Path()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("Файл конфигурации %s не найден, используются настройки по умолчанию", path)
+			return cfg, cfg.Validate()
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга %s: %v", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("некорректная конфигурация %s: %v", path, err)
+	}
+
+	log.Printf("Конфигурация загружена: %s", path)
+	return cfg, nil
+}
+
+// Parse читает и проверяет настройки из JSON без записи в файл
+func Parse(data []byte) (*Config, error) {
+	cfg := Default()
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("некорректная конфигурация: %v", err)
+	}
+	return cfg, nil
+}
+
+// Replace проверяет настройки и записывает их в файл конфигурации. Прежний файл
+// сохраняется с расширением .bak. Новые настройки применяются после перезапуска
+func Replace(data []byte) error {
+	if _, err := Parse(data); err != nil {
+		return err
+	}
+
+	path := Path()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		if err := os.Rename(path, path+".bak"); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Validate проверяет корректность настроек
+func (c *Config) Validate() error {
+	switch c.Schedule.OnWindowClose {
+	case "":
+		c.Schedule.OnWindowClose = OnWindowCloseFinish
+	case OnWindowCloseFinish, OnWindowCloseSuspend:
+	default:
+		return fmt.Errorf("schedule.onWindowClose: неизвестное значение %q", c.Schedule.OnWindowClose)
+	}
+
+	throttle := c.Throttle
+	if throttle.Nice < 0 || throttle.Nice > 19 {
+		return fmt.Errorf("throttle.nice: ожидается значение от 0 до 19")
+	}
+	switch throttle.IOClass {
+	case "", IOClassBestEffort, IOClassIdle:
+	default:
+		return fmt.Errorf("throttle.ioClass: неизвестное значение %q", throttle.IOClass)
+	}
+	if throttle.IOPriority < 0 || throttle.IOPriority > 7 {
+		return fmt.Errorf("throttle.ioPriority: ожидается значение от 0 до 7")
+	}
+	if throttle.Threads < 0 || throttle.MaxLoadAverage < 0 || throttle.MaxIOWait < 0 {
+		return fmt.Errorf("throttle: значения не могут быть отрицательными")
+	}
+
+	switch c.Disk.OnInsufficient {
+	case "":
+		c.Disk.OnInsufficient = OnInsufficientHold
+	case OnInsufficientHold, OnInsufficientFail:
+	default:
+		return fmt.Errorf("disk.onInsufficient: неизвестное значение %q", c.Disk.OnInsufficient)
+	}
+	if c.Disk.SafetyMarginPercent < 0 || c.Disk.MinFreeMB < 0 {
+		return fmt.Errorf("disk: значения не могут быть отрицательными")
+	}
+
+	if c.Scan.Workers < 0 {
+		return fmt.Errorf("scan.workers: значение не может быть отрицательным")
+	}
+	if c.Scan.Workers == 0 {
+		c.Scan.Workers = 2
+	}
+
+	if c.Search.MaxResults < 0 {
+		return fmt.Errorf("search.maxResults: значение не может быть отрицательным")
+	}
+	if c.Search.MaxResults == 0 {
+		c.Search.MaxResults = 1000
+	}
+	if c.Search.ExcludeDirs == nil {
+		c.Search.ExcludeDirs = []string{"@eaDir", "#recycle", "$RECYCLE.BIN", "lost+found", ".Trash*"}
+	}
+	for i, exclude := range c.Search.ExcludeDirs {
+		if filepath.IsAbs(exclude) {
+			c.Search.ExcludeDirs[i] = filepath.Clean(exclude)
+		} else if _, err := filepath.Match(exclude, ""); err != nil {
+			return fmt.Errorf("search.excludeDirs: некорректный шаблон %q", exclude)
+		}
+	}
+
+	if len(c.MediaRoots) == 0 {
+		return fmt.Errorf("mediaRoots: нужна хотя бы одна директория")
+	}
+	for i, root := range c.MediaRoots {
+		c.MediaRoots[i] = filepath.Clean(root)
+	}
+	switch c.Sidecars.Mode {
+	case "":
+		c.Sidecars.Mode = SidecarRename
+	case SidecarRename, SidecarCopy, SidecarOff:
+	default:
+		return fmt.Errorf("sidecars.mode: неизвестное значение %q", c.Sidecars.Mode)
+	}
+	if len(c.Sidecars.Extensions) == 0 {
+		c.Sidecars.Extensions = []string{"srt", "ass", "ssa", "sub", "idx", "sup", "vtt", "nfo", "jpg", "jpeg", "png", "tbn", "xml"}
+	}
+
+	if c.Quarantine.PurgeAfterDays < 0 {
+		return fmt.Errorf("quarantine.purgeAfterDays: значение не может быть отрицательным")
+	}
+
+	for i, window := range c.Schedule.Windows {
+		if _, err := window.Weekdays(); err != nil {
+			return fmt.Errorf("schedule.windows[%d]: %v", i, err)
+		}
+		if _, _, err := window.Bounds(); err != nil {
+			return fmt.Errorf("schedule.windows[%d]: %v", i, err)
+		}
+	}
+
+	if err := c.validateNotifiers(); err != nil {
+		return err
+	}
+	if err := c.validateWebhooks(); err != nil {
+		return err
+	}
+	if err := c.validateMQTT(); err != nil {
+		return err
+	}
+	if err := c.validateEmail(); err != nil {
+		return err
+	}
+	if err := c.validateHistory(); err != nil {
+		return err
+	}
+
+	if err := c.validateProfiles(); err != nil {
+		return err
+	}
+
+	return c.validateArr()
+}
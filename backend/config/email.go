@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+)
+
+// EmailConfig - отправка писем через SMTP об ошибках и ежедневной сводки
+type EmailConfig struct {
+	Enabled  bool   `json:"enabled"`
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// TLS - starttls (по умолчанию), tls (сразу TLS, обычно порт 465) или none
+	TLS  string   `json:"tls"`
+	From string   `json:"from"`
+	To   []string `json:"to"`
+	// OnFailure - письмо при ошибке задачи
+	OnFailure bool         `json:"onFailure"`
+	Digest    DigestConfig `json:"digest"`
+}
+
+// DigestConfig - ежедневная сводка по истории задач
+type DigestConfig struct {
+	Enabled bool `json:"enabled"`
+	// Time - время отправки в формате HH:MM по локальному времени сервера
+	Time string `json:"time"`
+}
+
+const (
+	EmailTLSStartTLS = "starttls"
+	EmailTLSImplicit = "tls"
+	EmailTLSNone     = "none"
+)
+
+// defaultEmail возвращает настройки почты по умолчанию, отправка выключена
+func defaultEmail() EmailConfig {
+	return EmailConfig{
+		OnFailure: true,
+		Digest: DigestConfig{
+			Time: "08:00",
+		},
+	}
+}
+
+// Minutes возвращает время отправки сводки в минутах от начала суток
+func (d DigestConfig) Minutes() (int, error) {
+	return parseClock(d.Time, 0)
+}
+
+// validateEmail заполняет значения по умолчанию и проверяет настройки почты
+func (c *Config) validateEmail() error {
+	email := &c.Email
+	if !email.Enabled {
+		return nil
+	}
+
+	if email.Host == "" {
+		return fmt.Errorf("email.host: нужно задать SMTP сервер")
+	}
+	switch email.TLS {
+	case "":
+		email.TLS = EmailTLSStartTLS
+	case EmailTLSStartTLS, EmailTLSImplicit, EmailTLSNone:
+	default:
+		return fmt.Errorf("email.tls: неизвестное значение %q", email.TLS)
+	}
+	if email.Port == 0 {
+		if email.TLS == EmailTLSImplicit {
+			email.Port = 465
+		} else {
+			email.Port = 587
+		}
+	}
+	if email.Port < 0 || email.Port > 65535 {
+		return fmt.Errorf("email.port: некорректный порт %d", email.Port)
+	}
+
+	if _, err := mail.ParseAddress(email.From); err != nil {
+		return fmt.Errorf("email.from: %v", err)
+	}
+	if len(email.To) == 0 {
+		return fmt.Errorf("email.to: нужен хотя бы один получатель")
+	}
+	for i, to := range email.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("email.to[%d]: %v", i, err)
+		}
+	}
+
+	if minutes, err := email.Digest.Minutes(); err != nil {
+		return fmt.Errorf("email.digest.time: %v", err)
+	} else if minutes >= 24*60 {
+		return fmt.Errorf("email.digest.time: ожидается время до 23:59")
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import "fmt"
+
+// HistoryConfig - сколько хранить завершенные задачи в tasks.json. Истекшие задачи
+// переносятся в архив tasks-archive.jsonl.gz. Нулевые значения - без ограничения
+type HistoryConfig struct {
+	// KeepDays - задачи, завершенные раньше стольких дней назад, переносятся в архив
+	KeepDays int `json:"keepDays"`
+	// KeepTasks - сколько последних завершенных задач без ошибки оставить в истории
+	KeepTasks int `json:"keepTasks"`
+	// KeepErrorDays - срок хранения задач с ошибкой, 0 - как KeepDays.
+	// Задачи с ошибкой не учитываются в KeepTasks
+	KeepErrorDays int `json:"keepErrorDays"`
+}
+
+// Enabled проверяет, задано ли хоть одно ограничение
+func (h HistoryConfig) Enabled() bool {
+	return h.KeepDays > 0 || h.KeepTasks > 0 || h.KeepErrorDays > 0
+}
+
+// ErrorDays возвращает срок хранения задач с ошибкой в днях
+func (h HistoryConfig) ErrorDays() int {
+	if h.KeepErrorDays > 0 {
+		return h.KeepErrorDays
+	}
+	return h.KeepDays
+}
+
+// validateHistory проверяет настройки хранения истории
+func (c *Config) validateHistory() error {
+	history := c.History
+	if history.KeepDays < 0 || history.KeepTasks < 0 || history.KeepErrorDays < 0 {
+		return fmt.Errorf("history: значения не могут быть отрицательными")
+	}
+	if history.KeepDays > 0 && history.KeepErrorDays > 0 && history.KeepErrorDays < history.KeepDays {
+		return fmt.Errorf("history.keepErrorDays: задачи с ошибкой хранятся не меньше keepDays")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// MQTTConfig - публикация состояния очереди в MQTT брокер (например, для Home Assistant)
+type MQTTConfig struct {
+	Enabled bool `json:"enabled"`
+	// Broker - адрес брокера, например tcp://mosquitto:1883
+	Broker   string `json:"broker"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	ClientID string `json:"clientId"`
+	// TopicPrefix - префикс топиков состояния и команд
+	TopicPrefix string `json:"topicPrefix"`
+	// Discovery - публиковать конфигурацию автообнаружения Home Assistant
+	Discovery bool `json:"discovery"`
+	// DiscoveryPrefix - префикс топиков автообнаружения Home Assistant
+	DiscoveryPrefix string `json:"discoveryPrefix"`
+}
+
+// defaultMQTT возвращает настройки MQTT по умолчанию, публикация выключена
+func defaultMQTT() MQTTConfig {
+	return MQTTConfig{
+		ClientID:        "dts-converter",
+		TopicPrefix:     "dts-converter",
+		Discovery:       true,
+		DiscoveryPrefix: "homeassistant",
+	}
+}
+
+// validateMQTT проверяет настройки MQTT
+func (c *Config) validateMQTT() error {
+	if !c.MQTT.Enabled {
+		return nil
+	}
+
+	if _, err := url.ParseRequestURI(c.MQTT.Broker); err != nil {
+		return fmt.Errorf("mqtt.broker: %v", err)
+	}
+
+	c.MQTT.TopicPrefix = strings.Trim(c.MQTT.TopicPrefix, "/")
+	c.MQTT.DiscoveryPrefix = strings.Trim(c.MQTT.DiscoveryPrefix, "/")
+	if c.MQTT.TopicPrefix == "" {
+		return fmt.Errorf("mqtt.topicPrefix: значение не может быть пустым")
+	}
+	if strings.ContainsAny(c.MQTT.TopicPrefix, "+#") {
+		return fmt.Errorf("mqtt.topicPrefix: символы + и # недопустимы")
+	}
+	if c.MQTT.Discovery && c.MQTT.DiscoveryPrefix == "" {
+		return fmt.Errorf("mqtt.discoveryPrefix: значение не может быть пустым")
+	}
+	if c.MQTT.ClientID == "" {
+		c.MQTT.ClientID = "dts-converter"
+	}
+
+	return nil
+}
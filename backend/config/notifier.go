@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// NotifierConfig - медиасервер, которому сообщается об измененной директории
+type NotifierConfig struct {
+	Name string `json:"name"`
+	// Type - jellyfin, emby или plex
+	Type string `json:"type"`
+	// URL - адрес сервера, например http://jellyfin:8096
+	URL string `json:"url"`
+	// Token - API ключ Jellyfin/Emby или X-Plex-Token
+	Token string `json:"token"`
+	// SectionID - номер библиотеки Plex для частичного сканирования
+	SectionID string `json:"sectionId"`
+	// PathMap - переписывание путей, если сервер видит медиатеку под другим путем
+	PathMap []PathMapping `json:"pathMap"`
+	// Retries - число повторов при ошибке, по умолчанию 3, 0 - без повторов
+	Retries *int `json:"retries"`
+	// RetryDelaySeconds - задержка перед первым повтором, далее удваивается
+	RetryDelaySeconds float64 `json:"retryDelaySeconds"`
+	// TimeoutSeconds - таймаут одного запроса
+	TimeoutSeconds float64 `json:"timeoutSeconds"`
+}
+
+// PathMapping - замена префикса пути между контейнерами
+type PathMapping struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+const (
+	NotifierJellyfin = "jellyfin"
+	NotifierEmby     = "emby"
+	NotifierPlex     = "plex"
+)
+
+// MapPath заменяет префикс пути по первому подходящему правилу
+func MapPath(path string, mappings []PathMapping) string {
+	for _, mapping := range mappings {
+		from := strings.TrimSuffix(mapping.From, "/")
+		if path == from || strings.HasPrefix(path, from+"/") {
+			return filepath.ToSlash(strings.TrimSuffix(mapping.To, "/") + strings.TrimPrefix(path, from))
+		}
+	}
+	return path
+}
+
+// validateNotifiers заполняет значения по умолчанию и проверяет уведомления медиасерверов
+func (c *Config) validateNotifiers() error {
+	names := make(map[string]bool)
+	for i := range c.Notifiers {
+		notifier := &c.Notifiers[i]
+
+		switch notifier.Type {
+		case NotifierJellyfin, NotifierEmby:
+		case NotifierPlex:
+			if notifier.SectionID == "" {
+				return fmt.Errorf("notifiers[%d]: для plex нужно задать sectionId", i)
+			}
+		default:
+			return fmt.Errorf("notifiers[%d].type: неизвестное значение %q", i, notifier.Type)
+		}
+
+		if _, err := url.ParseRequestURI(notifier.URL); err != nil {
+			return fmt.Errorf("notifiers[%d].url: %v", i, err)
+		}
+
+		if notifier.Name == "" {
+			notifier.Name = notifier.Type
+		}
+		if names[notifier.Name] {
+			return fmt.Errorf("notifiers[%d]: повторяющееся имя %q", i, notifier.Name)
+		}
+		names[notifier.Name] = true
+
+		if notifier.Retries == nil {
+			retries := 3
+			notifier.Retries = &retries
+		}
+		if *notifier.Retries < 0 || notifier.RetryDelaySeconds < 0 || notifier.TimeoutSeconds < 0 {
+			return fmt.Errorf("notifiers[%d]: значения не могут быть отрицательными", i)
+		}
+		if notifier.RetryDelaySeconds == 0 {
+			notifier.RetryDelaySeconds = 5
+		}
+		if notifier.TimeoutSeconds == 0 {
+			notifier.TimeoutSeconds = 10
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ProfileConfig - профиль конвертации: целевой формат и правила именования результата
+type ProfileConfig struct {
+	Name string `json:"name"`
+	// TargetCodec и TargetLayout подставляются в имя результата
+	TargetCodec  string       `json:"targetCodec"`
+	TargetLayout string       `json:"targetLayout"`
+	Naming       NamingConfig `json:"naming"`
+	// Finalize - что делать с исходным файлом после успешной конвертации
+	Finalize FinalizeConfig `json:"finalize"`
+}
+
+// FinalizeConfig - политика обработки исходного файла
+type FinalizeConfig struct {
+	// Policy - keep-bak (переименовать в .bak), quarantine (перенести в карантин)
+	// или replace (результат занимает имя исходного файла)
+	Policy string `json:"policy"`
+}
+
+// NamingConfig - шаблон имени выходного файла (без расширения)
+type NamingConfig struct {
+	// Template - шаблон с переменными {stem}, {sourceCodec}, {sourceLayout},
+	// {targetCodec}, {targetLayout}, {profile}, {year}, {resolution}
+	Template string `json:"template"`
+	// Replace - замены по регулярным выражениям, применяются к результату шаблона
+	Replace []ReplaceRule `json:"replace"`
+	// Collision - что делать, если файл с таким именем уже есть: suffix, overwrite, skip, fail
+	Collision string `json:"collision"`
+}
+
+// ReplaceRule - замена по регулярному выражению, Replacement может содержать
+// переменные шаблона и ссылки на группы ($1)
+type ReplaceRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+
+	// re - Pattern, скомпилированный при проверке конфигурации
+	re *regexp.Regexp
+}
+
+// Regexp возвращает скомпилированное выражение, nil - правило не прошло проверку
+func (r *ReplaceRule) Regexp() *regexp.Regexp {
+	return r.re
+}
+
+const (
+	CollisionSuffix    = "suffix"
+	CollisionOverwrite = "overwrite"
+	CollisionSkip      = "skip"
+	CollisionFail      = "fail"
+
+	FinalizeKeepBak    = "keep-bak"
+	FinalizeQuarantine = "quarantine"
+	FinalizeReplace    = "replace"
+
+	// DefaultProfileName - профиль, повторяющий исходное поведение DTS 5.1 -> FLAC 7.1
+	DefaultProfileName = "dts-to-flac-7.1"
+)
+
+// defaultProfile возвращает встроенный профиль
+func defaultProfile() ProfileConfig {
+	return ProfileConfig{
+		Name:         DefaultProfileName,
+		TargetCodec:  "FLAC",
+		TargetLayout: "7.1",
+		Naming: NamingConfig{
+			Template: "{stem}",
+			Replace: []ReplaceRule{
+				{Pattern: `DTS[.\-A-Za-z]*5\.1`, Replacement: "{targetCodec}.{targetLayout}"},
+			},
+			Collision: CollisionSuffix,
+		},
+		Finalize: FinalizeConfig{
+			Policy: FinalizeKeepBak,
+		},
+	}
+}
+
+// Profile возвращает профиль по имени, пустое имя - профиль по умолчанию
+func (c *Config) Profile(name string) (*ProfileConfig, error) {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+
+	for i := range c.Profiles {
+		if c.Profiles[i].Name == name {
+			return &c.Profiles[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("профиль %q не найден", name)
+}
+
+// validateProfiles заполняет значения по умолчанию и проверяет профили
+func (c *Config) validateProfiles() error {
+	if len(c.Profiles) == 0 {
+		c.Profiles = []ProfileConfig{defaultProfile()}
+	}
+	if c.DefaultProfile == "" {
+		c.DefaultProfile = c.Profiles[0].Name
+	}
+
+	names := make(map[string]bool)
+	for i := range c.Profiles {
+		profile := &c.Profiles[i]
+		if strings.TrimSpace(profile.Name) == "" {
+			return fmt.Errorf("profiles[%d]: пустое имя", i)
+		}
+		if names[profile.Name] {
+			return fmt.Errorf("profiles[%d]: повторяющееся имя %q", i, profile.Name)
+		}
+		names[profile.Name] = true
+
+		if profile.Naming.Template == "" {
+			profile.Naming.Template = "{stem}"
+		}
+		switch profile.Naming.Collision {
+		case "":
+			profile.Naming.Collision = CollisionSuffix
+		case CollisionSuffix, CollisionOverwrite, CollisionSkip, CollisionFail:
+		default:
+			return fmt.Errorf("profiles[%d].naming.collision: неизвестное значение %q", i, profile.Naming.Collision)
+		}
+		switch profile.Finalize.Policy {
+		case "":
+			profile.Finalize.Policy = FinalizeKeepBak
+		case FinalizeKeepBak, FinalizeReplace:
+		case FinalizeQuarantine:
+			if c.Quarantine.Dir == "" {
+				return fmt.Errorf("profiles[%d].finalize: для карантина нужно задать quarantine.dir", i)
+			}
+		default:
+			return fmt.Errorf("profiles[%d].finalize.policy: неизвестное значение %q", i, profile.Finalize.Policy)
+		}
+		for j := range profile.Naming.Replace {
+			rule := &profile.Naming.Replace[j]
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return fmt.Errorf("profiles[%d].naming.replace[%d]: %v", i, j, err)
+			}
+			rule.re = re
+		}
+	}
+
+	if !names[c.DefaultProfile] {
+		return fmt.Errorf("defaultProfile: профиль %q не найден", c.DefaultProfile)
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Weekdays возвращает набор дней недели, в которые окно начинается
+func (w ScheduleWindow) Weekdays() (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+
+	if len(w.Days) == 0 {
+		for _, day := range weekdayNames {
+			days[day] = true
+		}
+		return days, nil
+	}
+
+	for _, name := range w.Days {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "all":
+			for _, day := range weekdayNames {
+				days[day] = true
+			}
+		case "weekdays":
+			for day := time.Monday; day <= time.Friday; day++ {
+				days[day] = true
+			}
+		case "weekend":
+			days[time.Saturday] = true
+			days[time.Sunday] = true
+		default:
+			day, ok := weekdayNames[name]
+			if !ok {
+				return nil, fmt.Errorf("неизвестный день недели %q", name)
+			}
+			days[day] = true
+		}
+	}
+
+	return days, nil
+}
+
+// Bounds возвращает начало и конец окна в минутах от полуночи.
+// Конец меньше или равен началу, если окно переходит через полночь
+// или занимает весь день
+func (w ScheduleWindow) Bounds() (int, int, error) {
+	start, err := parseClock(w.Start, 0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("start: %v", err)
+	}
+
+	end, err := parseClock(w.End, 24*60)
+	if err != nil {
+		return 0, 0, fmt.Errorf("end: %v", err)
+	}
+
+	return start, end, nil
+}
+
+// parseClock разбирает время в формате HH:MM, допускается 24:00
+func parseClock(value string, empty int) (int, error) {
+	if value == "" {
+		return empty, nil
+	}
+
+	var hours, minutes int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hours, &minutes); err != nil {
+		return 0, fmt.Errorf("ожидается формат HH:MM, получено %q", value)
+	}
+
+	total := hours*60 + minutes
+	if hours < 0 || minutes < 0 || minutes > 59 || total > 24*60 {
+		return 0, fmt.Errorf("некорректное время %q", value)
+	}
+
+	return total, nil
+}
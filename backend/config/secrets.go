@@ -0,0 +1,108 @@
+package config
+
+import "encoding/json"
+
+// secretFields - поля файла конфигурации с паролями и ключами: путь в JSON,
+// "*" - любой элемент массива
+var secretFields = [][]string{
+	{"adminToken"},
+	{"arr", "token"},
+	{"email", "password"},
+	{"mqtt", "password"},
+	{"webhooks", "*", "secret"},
+	{"notifiers", "*", "token"},
+}
+
+// StripSecrets возвращает файл конфигурации без паролей и ключей
+func StripSecrets(data []byte) ([]byte, error) {
+	var tree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	for _, path := range secretFields {
+		stripSecret(tree, path)
+	}
+	return json.MarshalIndent(tree, "", "  ")
+}
+
+// RestoreSecrets переносит в data пароли и ключи из текущего файла конфигурации current
+// для полей, которых в data нет. Элементы массивов сопоставляются по name, без него - по номеру
+func RestoreSecrets(data, current []byte) ([]byte, error) {
+	var tree, currentTree interface{}
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(current, &currentTree); err != nil {
+		return nil, err
+	}
+	for _, path := range secretFields {
+		restoreSecret(tree, currentTree, path)
+	}
+	return json.MarshalIndent(tree, "", "  ")
+}
+
+func stripSecret(node interface{}, path []string) {
+	if path[0] == "*" {
+		items, _ := node.([]interface{})
+		for _, item := range items {
+			stripSecret(item, path[1:])
+		}
+		return
+	}
+
+	fields, ok := node.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		delete(fields, path[0])
+		return
+	}
+	stripSecret(fields[path[0]], path[1:])
+}
+
+func restoreSecret(node, current interface{}, path []string) {
+	if path[0] == "*" {
+		items, _ := node.([]interface{})
+		currentItems, _ := current.([]interface{})
+		for i, item := range items {
+			if match := matchItem(item, currentItems, i); match != nil {
+				restoreSecret(item, match, path[1:])
+			}
+		}
+		return
+	}
+
+	fields, ok := node.(map[string]interface{})
+	currentFields, currentOK := current.(map[string]interface{})
+	if !ok || !currentOK {
+		return
+	}
+	if len(path) == 1 {
+		if _, exists := fields[path[0]]; !exists {
+			if value, found := currentFields[path[0]]; found {
+				fields[path[0]] = value
+			}
+		}
+		return
+	}
+	restoreSecret(fields[path[0]], currentFields[path[0]], path[1:])
+}
+
+// matchItem находит в items элемент с тем же name, что у item, или элемент с номером index
+func matchItem(item interface{}, items []interface{}, index int) interface{} {
+	fields, _ := item.(map[string]interface{})
+	if name, _ := fields["name"].(string); name != "" {
+		for _, candidate := range items {
+			candidateFields, _ := candidate.(map[string]interface{})
+			if candidateName, _ := candidateFields["name"].(string); candidateName == name {
+				return candidate
+			}
+		}
+		return nil
+	}
+	if index < len(items) {
+		return items[index]
+	}
+	return nil
+}
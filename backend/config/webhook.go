@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// WebhookConfig - внешний HTTP обработчик событий жизненного цикла задач
+type WebhookConfig struct {
+	Name string `json:"name"`
+	// URL - адрес, на который отправляется POST с JSON телом события
+	URL string `json:"url"`
+	// Secret - ключ подписи HMAC-SHA256, пусто - без подписи
+	Secret string `json:"secret"`
+	// Events - события, которые нужно отправлять, пусто - все
+	Events []string `json:"events"`
+	// ProgressMilestones - проценты прогресса, при прохождении которых отправляется task.progress
+	ProgressMilestones []int `json:"progressMilestones"`
+	// Retries - число повторов при ошибке, по умолчанию 3, 0 - без повторов
+	Retries *int `json:"retries"`
+	// RetryDelaySeconds - задержка перед первым повтором, далее удваивается
+	RetryDelaySeconds float64 `json:"retryDelaySeconds"`
+	// TimeoutSeconds - таймаут одного запроса
+	TimeoutSeconds float64 `json:"timeoutSeconds"`
+}
+
+const (
+	EventTaskCreated   = "task.created"
+	EventTaskStarted   = "task.started"
+	EventTaskProgress  = "task.progress"
+	EventTaskPaused    = "task.paused"
+	EventTaskResumed   = "task.resumed"
+	EventTaskCompleted = "task.completed"
+	EventTaskFailed    = "task.failed"
+	EventTaskCancelled = "task.cancelled"
+	EventTaskSkipped   = "task.skipped"
+	EventTaskReverted  = "task.reverted"
+)
+
+// WebhookEvents - все события, которые можно отправлять в webhook
+var WebhookEvents = []string{
+	EventTaskCreated, EventTaskStarted, EventTaskProgress, EventTaskPaused, EventTaskResumed,
+	EventTaskCompleted, EventTaskFailed, EventTaskCancelled, EventTaskSkipped, EventTaskReverted,
+}
+
+// Accepts возвращает true, если webhook подписан на событие
+func (w *WebhookConfig) Accepts(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// validateWebhooks заполняет значения по умолчанию и проверяет webhooks
+func (c *Config) validateWebhooks() error {
+	known := make(map[string]bool)
+	for _, event := range WebhookEvents {
+		known[event] = true
+	}
+
+	names := make(map[string]bool)
+	for i := range c.Webhooks {
+		webhook := &c.Webhooks[i]
+
+		parsed, err := url.ParseRequestURI(webhook.URL)
+		if err != nil {
+			return fmt.Errorf("webhooks[%d].url: %v", i, err)
+		}
+		if webhook.Name == "" {
+			webhook.Name = parsed.Host
+		}
+		if names[webhook.Name] {
+			return fmt.Errorf("webhooks[%d]: повторяющееся имя %q", i, webhook.Name)
+		}
+		names[webhook.Name] = true
+
+		for _, event := range webhook.Events {
+			if !known[event] {
+				return fmt.Errorf("webhooks[%d].events: неизвестное событие %q", i, event)
+			}
+		}
+
+		if webhook.ProgressMilestones == nil {
+			webhook.ProgressMilestones = []int{25, 50, 75}
+		}
+		for _, milestone := range webhook.ProgressMilestones {
+			if milestone <= 0 || milestone >= 100 {
+				return fmt.Errorf("webhooks[%d].progressMilestones: ожидаются значения от 1 до 99", i)
+			}
+		}
+
+		if webhook.Retries == nil {
+			retries := 3
+			webhook.Retries = &retries
+		}
+		if *webhook.Retries < 0 || webhook.RetryDelaySeconds < 0 || webhook.TimeoutSeconds < 0 {
+			return fmt.Errorf("webhooks[%d]: значения не могут быть отрицательными", i)
+		}
+		if webhook.RetryDelaySeconds == 0 {
+			webhook.RetryDelaySeconds = 2
+		}
+		if webhook.TimeoutSeconds == 0 {
+			webhook.TimeoutSeconds = 10
+		}
+	}
+
+	return nil
+}
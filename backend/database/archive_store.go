@@ -0,0 +1,109 @@
+package database
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// ArchiveStore - архив задач, удаленных из истории, в сжатом файле JSON Lines.
+// Каждый перенос дописывает в конец файла отдельный блок gzip, поэтому файл
+// не переписывается целиком и читается обычным zcat
+type ArchiveStore struct {
+	mu       sync.Mutex
+	filePath string
+}
+
+// NewArchiveStore создает архив, сам файл появляется при первом переносе
+func NewArchiveStore(filePath string) (*ArchiveStore, error) {
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, err
+	}
+	return &ArchiveStore{filePath: filePath}, nil
+}
+
+// Path возвращает путь к файлу архива
+func (s *ArchiveStore) Path() string {
+	return s.filePath
+}
+
+// Append дописывает задачи в архив и сбрасывает файл на диск
+func (s *ArchiveStore) Append(tasks []*models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	zw := gzip.NewWriter(file)
+	encoder := json.NewEncoder(zw)
+	for _, task := range tasks {
+		if err := encoder.Encode(task); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// Open открывает файл архива для выгрузки как есть. Пока файл открыт, перенос
+// в архив может дописать новый блок, поэтому читать стоит не дальше размера на момент открытия
+func (s *ArchiveStore) Open() (*os.File, error) {
+	return os.Open(s.filePath)
+}
+
+// Each читает задачи архива по порядку переноса. Недописанный последний блок
+// (сбой во время переноса) пропускается с предупреждением
+func (s *ArchiveStore) Each(fn func(*models.Task) error) error {
+	file, err := os.Open(s.filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(file))
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	decoder := json.NewDecoder(zr)
+	for {
+		var task models.Task
+		if err := decoder.Decode(&task); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, gzip.ErrChecksum) {
+				log.Printf("Архив задач %s поврежден в конце, прочитаны только целые записи: %v", s.filePath, err)
+				return nil
+			}
+			return err
+		}
+		if err := fn(&task); err != nil {
+			return err
+		}
+	}
+}
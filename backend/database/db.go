@@ -4,12 +4,16 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 	"ultimate-dts-fix-server/backend/models"
 )
 
 // TaskRepository предоставляет методы для работы с задачами
 type TaskRepository struct {
-	store *JSONStore
+	store     *JSONStore
+	state     *StateStore
+	inventory *InventoryStore
+	archive   *ArchiveStore
 }
 
 // InitDB инициализирует хранилище данных
@@ -26,15 +30,46 @@ func InitDB() (*TaskRepository, error) {
 		dbPath = envPath
 	}
 
+	repo, err := OpenDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("JSON хранилище инициализировано: %s", dbPath)
+
+	return repo, nil
+}
+
+// OpenDB открывает хранилище задач dbPath, служебные файлы лежат рядом с ним
+func OpenDB(dbPath string) (*TaskRepository, error) {
 	// Создаем JSON store
 	store, err := NewJSONStore(dbPath)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("JSON хранилище инициализировано: %s", dbPath)
+	// Служебное состояние храним рядом с задачами
+	statePath := filepath.Join(filepath.Dir(dbPath), "state.json")
+	state, err := NewStateStore(statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Опись медиатеки может быть большой, поэтому хранится в отдельном файле
+	inventoryPath := filepath.Join(filepath.Dir(dbPath), "inventory.json")
+	inventory, err := NewInventoryStore(inventoryPath)
+	if err != nil {
+		return nil, err
+	}
 
-	return &TaskRepository{store: store}, nil
+	// Задачи, удаленные из истории, переносятся в сжатый архив
+	archivePath := filepath.Join(filepath.Dir(dbPath), "tasks-archive.jsonl.gz")
+	archive, err := NewArchiveStore(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TaskRepository{store: store, state: state, inventory: inventory, archive: archive}, nil
 }
 
 // CreateTask создает новую задачу
@@ -47,7 +82,7 @@ func (r *TaskRepository) UpdateTask(task *models.Task) error {
 	return r.store.UpdateTask(task)
 }
 
-// GetPendingTasks возвращает задачи в статусе pending или processing
+// GetPendingTasks возвращает задачи в статусе pending, processing или paused
 func (r *TaskRepository) GetPendingTasks() ([]*models.Task, error) {
 	return r.store.GetPendingTasks()
 }
@@ -57,17 +92,77 @@ func (r *TaskRepository) GetAllTasks() ([]*models.Task, error) {
 	return r.store.GetAllTasks()
 }
 
+// ListTasks возвращает страницу задач по фильтру
+func (r *TaskRepository) ListTasks(query TaskQuery) (*TaskPage, error) {
+	return r.store.ListTasks(query)
+}
+
+// GetFinishedTasksSince возвращает завершенные, ошибочные, пропущенные и откаченные задачи,
+// изменившиеся после since
+func (r *TaskRepository) GetFinishedTasksSince(since time.Time) ([]*models.Task, error) {
+	return r.store.GetFinishedTasksSince(since)
+}
+
 // GetTask возвращает задачу по ID
 func (r *TaskRepository) GetTask(taskID string) (*models.Task, error) {
 	return r.store.GetTask(taskID)
 }
 
+// FindTasksByPath возвращает задачи, у которых исходный или выходной файл - path, новые первыми
+func (r *TaskRepository) FindTasksByPath(path string) []*models.Task {
+	return r.store.FindTasksByPath(path)
+}
+
 // DeleteTask удаляет задачу по ID
 func (r *TaskRepository) DeleteTask(taskID string) error {
 	return r.store.DeleteTask(taskID)
 }
 
+// PutTasks создает или заменяет задачи пакетом, например при импорте
+func (r *TaskRepository) PutTasks(tasks []*models.Task) error {
+	return r.store.PutTasks(tasks)
+}
+
+// ArchiveTasks переносит завершенные задачи из истории в архив. Задачи удаляются из
+// tasks.json только после записи в архив. Возвращает перенесенные задачи
+func (r *TaskRepository) ArchiveTasks(taskIDs []string) ([]*models.Task, error) {
+	return r.store.RemoveTasks(taskIDs, r.archive.Append)
+}
+
+// PurgeTasks удаляет завершенные задачи из истории без архивации
+func (r *TaskRepository) PurgeTasks(taskIDs []string) ([]*models.Task, error) {
+	return r.store.RemoveTasks(taskIDs, nil)
+}
+
+// Archive возвращает архив задач, удаленных из истории
+func (r *TaskRepository) Archive() *ArchiveStore {
+	return r.archive
+}
+
+// GetState читает служебное значение по ключу, возвращает false если его нет
+func (r *TaskRepository) GetState(key string, v interface{}) (bool, error) {
+	return r.state.Get(key, v)
+}
+
+// SetState сохраняет служебное значение по ключу
+func (r *TaskRepository) SetState(key string, v interface{}) error {
+	return r.state.Set(key, v)
+}
+
+// Inventory возвращает хранилище описи аудиодорожек медиатеки
+func (r *TaskRepository) Inventory() *InventoryStore {
+	return r.inventory
+}
+
+// LatestTasksByFilePath возвращает последнюю задачу для каждого исходного файла
+func (r *TaskRepository) LatestTasksByFilePath() map[string]*models.Task {
+	return r.store.LatestTasksByFilePath()
+}
+
 // Close закрывает хранилище
 func (r *TaskRepository) Close() error {
+	if err := r.inventory.Flush(); err != nil {
+		log.Printf("Ошибка сохранения описи медиатеки: %v", err)
+	}
 	return r.store.Close()
 }
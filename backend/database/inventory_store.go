@@ -0,0 +1,170 @@
+package database
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// InventoryStore - опись аудиодорожек файлов медиатеки в отдельном JSON файле,
+// она же кэш результатов ffprobe. Изменения накапливаются в памяти
+// и записываются методом Flush или автосохранением
+type InventoryStore struct {
+	files    map[string]*models.MediaFile
+	dirty    bool
+	mu       sync.RWMutex
+	filePath string
+}
+
+// NewInventoryStore создает хранилище описи
+func NewInventoryStore(filePath string) (*InventoryStore, error) {
+	store := &InventoryStore{
+		files:    make(map[string]*models.MediaFile),
+		filePath: filePath,
+	}
+
+	// Создаем директорию если не существует
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// Загружаем существующие данные
+	if err := store.load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// Запускаем автосохранение каждые 30 секунд
+	go store.autoSave()
+
+	return store, nil
+}
+
+// Put добавляет или заменяет запись о файле
+func (s *InventoryStore) Put(file *models.MediaFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[file.Path] = file
+	s.dirty = true
+}
+
+// Get возвращает запись о файле или nil
+func (s *InventoryStore) Get(path string) *models.MediaFile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.files[path]
+}
+
+// Delete удаляет записи о файлах
+func (s *InventoryStore) Delete(paths ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, path := range paths {
+		if _, exists := s.files[path]; exists {
+			delete(s.files, path)
+			s.dirty = true
+		}
+	}
+}
+
+// Prune удаляет записи о файлах внутри roots, которых нет в keep,
+// и возвращает число удаленных записей
+func (s *InventoryStore) Prune(roots []string, keep map[string]bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for path := range s.files {
+		if keep[path] || !underAnyRoot(path, roots) {
+			continue
+		}
+		delete(s.files, path)
+		removed++
+	}
+	if removed > 0 {
+		s.dirty = true
+	}
+	return removed
+}
+
+// All возвращает все записи, отсортированные по пути
+func (s *InventoryStore) All() []*models.MediaFile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files := make([]*models.MediaFile, 0, len(s.files))
+	for _, file := range s.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// Flush записывает файл, если были изменения
+func (s *InventoryStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// autoSave периодически сохраняет изменения
+func (s *InventoryStore) autoSave() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.Flush(); err != nil {
+			log.Printf("Ошибка сохранения описи медиатеки: %v", err)
+		}
+	}
+}
+
+// save сохраняет данные в файл
+func (s *InventoryStore) save() error {
+	data, err := json.Marshal(s.files)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.filePath, data, 0644)
+}
+
+// load загружает данные из файла
+func (s *InventoryStore) load() error {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &s.files)
+}
+
+// underAnyRoot возвращает true, если путь лежит внутри одной из директорий
+func underAnyRoot(path string, roots []string) bool {
+	for _, root := range roots {
+		prefix := strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator)
+		if path == root || strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,10 @@ package database
 
 import (
 	"encoding/json"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 	"ultimate-dts-fix-server/backend/models"
@@ -11,7 +13,10 @@ import (
 
 // JSONStore - простое хранилище на основе JSON файла
 type JSONStore struct {
-	tasks    map[string]*models.Task
+	tasks map[string]*models.Task
+	// index - задачи по путям файлов, legacy - новые идентификаторы по старым
+	index    *taskIndex
+	legacy   map[string]string
 	mu       sync.RWMutex
 	filePath string
 }
@@ -20,6 +25,8 @@ type JSONStore struct {
 func NewJSONStore(filePath string) (*JSONStore, error) {
 	store := &JSONStore{
 		tasks:    make(map[string]*models.Task),
+		index:    newTaskIndex(),
+		legacy:   make(map[string]string),
 		filePath: filePath,
 	}
 
@@ -46,6 +53,7 @@ func (s *JSONStore) CreateTask(task *models.Task) error {
 	defer s.mu.Unlock()
 
 	s.tasks[task.ID] = task
+	s.index.add(task)
 	return s.save()
 }
 
@@ -55,34 +63,31 @@ func (s *JSONStore) UpdateTask(task *models.Task) error {
 	defer s.mu.Unlock()
 
 	s.tasks[task.ID] = task
+	s.index.add(task)
 	return s.save()
 }
 
-// GetPendingTasks возвращает задачи в статусе pending или processing
+// GetPendingTasks возвращает задачи в статусе pending, processing или paused
 func (s *JSONStore) GetPendingTasks() ([]*models.Task, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var tasks []*models.Task
 	for _, task := range s.tasks {
-		if task.Status == models.StatusPending || task.Status == models.StatusProcessing {
+		if task.IsActive() {
 			tasks = append(tasks, task)
 		}
 	}
 
 	// Сортируем по времени создания
-	for i := 0; i < len(tasks)-1; i++ {
-		for j := i + 1; j < len(tasks); j++ {
-			if tasks[i].CreatedAt.After(tasks[j].CreatedAt) {
-				tasks[i], tasks[j] = tasks[j], tasks[i]
-			}
-		}
-	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return createdBefore(tasks[i], tasks[j])
+	})
 
 	return tasks, nil
 }
 
-// GetAllTasks возвращает все задачи
+// GetAllTasks возвращает все задачи, новые первыми. Для просмотра истории - ListTasks
 func (s *JSONStore) GetAllTasks() ([]*models.Task, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -93,28 +98,62 @@ func (s *JSONStore) GetAllTasks() ([]*models.Task, error) {
 	}
 
 	// Сортируем по времени создания (новые первыми)
-	for i := 0; i < len(tasks)-1; i++ {
-		for j := i + 1; j < len(tasks); j++ {
-			if tasks[i].CreatedAt.Before(tasks[j].CreatedAt) {
-				tasks[i], tasks[j] = tasks[j], tasks[i]
-			}
-		}
+	sort.Slice(tasks, func(i, j int) bool {
+		return createdBefore(tasks[j], tasks[i])
+	})
+
+	return tasks, nil
+}
+
+// createdBefore сравнивает задачи по времени создания, при равном времени - по ID,
+// чтобы порядок не зависел от обхода map
+func createdBefore(a, b *models.Task) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
 	}
+	return a.ID < b.ID
+}
+
+// GetFinishedTasksSince возвращает задачи в конечном статусе, изменившиеся после since,
+// в порядке изменения
+func (s *JSONStore) GetFinishedTasksSince(since time.Time) ([]*models.Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	// Ограничиваем до 100 задач
-	if len(tasks) > 100 {
-		tasks = tasks[:100]
+	var tasks []*models.Task
+	for _, task := range s.tasks {
+		if !task.IsActive() && task.ActivityAt().After(since) {
+			tasks = append(tasks, task)
+		}
 	}
 
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].ActivityAt().Before(tasks[j].ActivityAt())
+	})
+
 	return tasks, nil
 }
 
-// GetTask возвращает задачу по ID
+// LatestTasksByFilePath возвращает последнюю созданную задачу для каждого исходного файла
+func (s *JSONStore) LatestTasksByFilePath() map[string]*models.Task {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	latest := make(map[string]*models.Task)
+	for _, task := range s.tasks {
+		if current, exists := latest[task.FilePath]; !exists || task.CreatedAt.After(current.CreatedAt) {
+			latest[task.FilePath] = task
+		}
+	}
+	return latest
+}
+
+// GetTask возвращает задачу по ID, в том числе по идентификатору до перехода на ULID
 func (s *JSONStore) GetTask(taskID string) (*models.Task, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	task, exists := s.tasks[taskID]
+	task, exists := s.tasks[s.resolveID(taskID)]
 	if !exists {
 		return nil, nil
 	}
@@ -122,15 +161,96 @@ func (s *JSONStore) GetTask(taskID string) (*models.Task, error) {
 	return task, nil
 }
 
+// FindTasksByPath возвращает задачи, у которых исходный или выходной файл - path, новые первыми
+func (s *JSONStore) FindTasksByPath(path string) []*models.Task {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var tasks []*models.Task
+	for _, id := range s.index.lookup(path) {
+		tasks = append(tasks, s.tasks[id])
+	}
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].CreatedAt.After(tasks[j].CreatedAt)
+	})
+	return tasks
+}
+
 // DeleteTask удаляет задачу по ID
 func (s *JSONStore) DeleteTask(taskID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	taskID = s.resolveID(taskID)
+	if task, exists := s.tasks[taskID]; exists && task.LegacyID != "" {
+		delete(s.legacy, task.LegacyID)
+	}
 	delete(s.tasks, taskID)
+	s.index.remove(taskID)
+	return s.save()
+}
+
+// PutTasks создает или заменяет задачи одним сохранением
+func (s *JSONStore) PutTasks(tasks []*models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, task := range tasks {
+		s.tasks[task.ID] = task
+		s.index.add(task)
+		if task.LegacyID != "" {
+			s.legacy[task.LegacyID] = task.ID
+		}
+	}
 	return s.save()
 }
 
+// RemoveTasks удаляет завершенные задачи одним сохранением. Перед удалением под блокировкой
+// вызывается before с найденными задачами: если он вернул ошибку, ничего не удаляется.
+// Незавершенные и неизвестные задачи пропускаются. Возвращает удаленные задачи
+func (s *JSONStore) RemoveTasks(taskIDs []string, before func([]*models.Task) error) ([]*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tasks []*models.Task
+	for _, taskID := range taskIDs {
+		task, exists := s.tasks[s.resolveID(taskID)]
+		if !exists || task.IsActive() {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	if before != nil {
+		if err := before(tasks); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, task := range tasks {
+		if task.LegacyID != "" {
+			delete(s.legacy, task.LegacyID)
+		}
+		delete(s.tasks, task.ID)
+		s.index.remove(task.ID)
+	}
+	return tasks, s.save()
+}
+
+// resolveID заменяет старый идентификатор задачи на новый
+func (s *JSONStore) resolveID(taskID string) string {
+	if _, exists := s.tasks[taskID]; !exists {
+		if newID, migrated := s.legacy[taskID]; migrated {
+			return newID
+		}
+	}
+	return taskID
+}
+
 // save сохраняет данные в файл
 func (s *JSONStore) save() error {
 	data, err := json.MarshalIndent(s.tasks, "", "  ")
@@ -148,7 +268,50 @@ func (s *JSONStore) load() error {
 		return err
 	}
 
-	return json.Unmarshal(data, &s.tasks)
+	if err := json.Unmarshal(data, &s.tasks); err != nil {
+		return err
+	}
+
+	if migrated := s.migrateIDs(); migrated > 0 {
+		log.Printf("Идентификаторы задач переведены на ULID: %d", migrated)
+		if err := s.save(); err != nil {
+			return err
+		}
+	}
+
+	for _, task := range s.tasks {
+		s.index.add(task)
+		if task.LegacyID != "" {
+			s.legacy[task.LegacyID] = task.ID
+		}
+	}
+	return nil
+}
+
+// migrateIDs заменяет идентификаторы вида 20060102150405 на ULID по времени создания
+// задачи. Старый идентификатор сохраняется в LegacyID, по нему задача по-прежнему находится
+func (s *JSONStore) migrateIDs() int {
+	var legacy []*models.Task
+	for id, task := range s.tasks {
+		// Ключ карты главнее поля: раньше задачи с одинаковым ID перезаписывали друг друга
+		task.ID = id
+		if !models.IsTaskID(id) {
+			legacy = append(legacy, task)
+		}
+	}
+
+	// По порядку создания, чтобы новые идентификаторы сортировались так же
+	sort.Slice(legacy, func(i, j int) bool {
+		return createdBefore(legacy[i], legacy[j])
+	})
+
+	for _, task := range legacy {
+		delete(s.tasks, task.ID)
+		task.LegacyID = task.ID
+		task.ID = models.TaskIDAt(task.CreatedAt)
+		s.tasks[task.ID] = task
+	}
+	return len(legacy)
 }
 
 // autoSave периодически сохраняет данные
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// writeTasksFile записывает tasks.json в формате хранилища: задачи по идентификаторам
+func writeTasksFile(t *testing.T, path string, tasks map[string]*models.Task) {
+	t.Helper()
+	data, err := json.Marshal(tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateIDs(t *testing.T) {
+	base := time.Date(2023, 3, 10, 9, 0, 0, 0, time.UTC)
+	current := models.TaskIDAt(base.Add(time.Hour))
+
+	tests := []struct {
+		name  string
+		tasks map[string]*models.Task
+		// legacy - старые идентификаторы в порядке создания задач
+		legacy []string
+		// kept - идентификаторы, которые не должны меняться
+		kept []string
+	}{
+		{
+			name: "legacy only",
+			tasks: map[string]*models.Task{
+				"20230310090002": {FilePath: "/media/b.mkv", Status: models.StatusCompleted, CreatedAt: base.Add(2 * time.Second)},
+				"20230310090001": {FilePath: "/media/a.mkv", Status: models.StatusCompleted, CreatedAt: base.Add(time.Second)},
+				"20230310090003": {FilePath: "/media/c.mkv", Status: models.StatusPending, CreatedAt: base.Add(3 * time.Second)},
+			},
+			legacy: []string{"20230310090001", "20230310090002", "20230310090003"},
+		},
+		{
+			name: "legacy created in the same second",
+			tasks: map[string]*models.Task{
+				"20230310090001":   {FilePath: "/media/a.mkv", Status: models.StatusError, CreatedAt: base},
+				"20230310090001-2": {FilePath: "/media/b.mkv", Status: models.StatusError, CreatedAt: base},
+			},
+			legacy: []string{"20230310090001", "20230310090001-2"},
+		},
+		{
+			name: "mixed with ULID",
+			tasks: map[string]*models.Task{
+				"20230310090001": {FilePath: "/media/a.mkv", Status: models.StatusCompleted, CreatedAt: base},
+				current:          {ID: current, FilePath: "/media/b.mkv", Status: models.StatusCompleted, CreatedAt: base.Add(time.Hour)},
+			},
+			legacy: []string{"20230310090001"},
+			kept:   []string{current},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tasks.json")
+			writeTasksFile(t, path, tt.tasks)
+
+			store, err := NewJSONStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			migrated := make(map[string]string)
+			for _, legacyID := range tt.legacy {
+				task, err := store.GetTask(legacyID)
+				if err != nil || task == nil {
+					t.Fatalf("GetTask(%q) = %v, %v", legacyID, task, err)
+				}
+				if !models.IsTaskID(task.ID) || task.LegacyID != legacyID {
+					t.Fatalf("GetTask(%q) = ID %q, LegacyID %q", legacyID, task.ID, task.LegacyID)
+				}
+				migrated[legacyID] = task.ID
+			}
+			for i := 1; i < len(tt.legacy); i++ {
+				if migrated[tt.legacy[i-1]] >= migrated[tt.legacy[i]] {
+					t.Errorf("new IDs are not in creation order: %q >= %q", migrated[tt.legacy[i-1]], migrated[tt.legacy[i]])
+				}
+			}
+			for _, id := range tt.kept {
+				if task, err := store.GetTask(id); err != nil || task == nil || task.LegacyID != "" {
+					t.Errorf("GetTask(%q) = %+v, %v, want unchanged task", id, task, err)
+				}
+			}
+
+			// Повторная загрузка уже переведенного файла ничего не меняет
+			reopened, err := NewJSONStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reopened.migrateIDs(); got != 0 {
+				t.Errorf("migrateIDs() after reload = %d, want 0", got)
+			}
+			if got, want := taskIDs(t, reopened), taskIDs(t, store); !equalStrings(got, want) {
+				t.Errorf("IDs after reload = %v, want %v", got, want)
+			}
+			for legacyID, id := range migrated {
+				task, err := reopened.GetTask(legacyID)
+				if err != nil || task == nil || task.ID != id {
+					t.Errorf("GetTask(%q) after reload = %v, %v, want %q", legacyID, task, err, id)
+				}
+			}
+		})
+	}
+}
+
+func taskIDs(t *testing.T, store *JSONStore) []string {
+	t.Helper()
+	tasks, err := store.GetAllTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StateStore - хранилище служебного состояния приложения (флаги, отметки времени)
+// в отдельном JSON файле рядом с задачами
+type StateStore struct {
+	values   map[string]json.RawMessage
+	mu       sync.RWMutex
+	filePath string
+}
+
+// NewStateStore создает хранилище состояния
+func NewStateStore(filePath string) (*StateStore, error) {
+	store := &StateStore{
+		values:   make(map[string]json.RawMessage),
+		filePath: filePath,
+	}
+
+	// Создаем директорию если не существует
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	// Загружаем существующие данные
+	if err := store.load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Get читает значение по ключу в v, возвращает false если ключ не найден
+func (s *StateStore) Get(key string, v interface{}) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	raw, exists := s.values[key]
+	if !exists {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(raw, v)
+}
+
+// Set сохраняет значение по ключу и сразу записывает файл
+func (s *StateStore) Set(key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values[key] = raw
+	return s.save()
+}
+
+// save сохраняет данные в файл
+func (s *StateStore) save() error {
+	data, err := json.MarshalIndent(s.values, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.filePath, data, 0644)
+}
+
+// load загружает данные из файла
+func (s *StateStore) load() error {
+	data, err := os.ReadFile(s.filePath)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &s.values)
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"path/filepath"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// taskIndex - индекс задач по путям исходного и выходного файлов
+type taskIndex struct {
+	byPath map[string]map[string]bool
+	// paths - под какими путями проиндексирована задача, чтобы убрать их при изменении
+	paths map[string][]string
+}
+
+func newTaskIndex() *taskIndex {
+	return &taskIndex{
+		byPath: make(map[string]map[string]bool),
+		paths:  make(map[string][]string),
+	}
+}
+
+// add индексирует задачу заново, ее пути могли измениться с прошлого раза
+func (i *taskIndex) add(task *models.Task) {
+	i.remove(task.ID)
+
+	var paths []string
+	for _, path := range []string{task.FilePath, task.OutputPath} {
+		if path == "" {
+			continue
+		}
+		path = filepath.Clean(path)
+		if i.byPath[path] == nil {
+			i.byPath[path] = make(map[string]bool)
+		}
+		i.byPath[path][task.ID] = true
+		paths = append(paths, path)
+	}
+	i.paths[task.ID] = paths
+}
+
+// remove убирает задачу из индекса
+func (i *taskIndex) remove(taskID string) {
+	for _, path := range i.paths[taskID] {
+		delete(i.byPath[path], taskID)
+		if len(i.byPath[path]) == 0 {
+			delete(i.byPath, path)
+		}
+	}
+	delete(i.paths, taskID)
+}
+
+// lookup возвращает идентификаторы задач, у которых исходный или выходной файл - path
+func (i *taskIndex) lookup(path string) []string {
+	var ids []string
+	for id := range i.byPath[filepath.Clean(path)] {
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,206 @@
+package database
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+const (
+	SortCreated  = "created"
+	SortActivity = "activity"
+	SortName     = "name"
+	SortSize     = "size"
+
+	// DefaultPageSize и MaxPageSize - размер страницы list_tasks
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// TaskQuery - фильтр, сортировка и страница списка задач. Пустые поля не учитываются
+type TaskQuery struct {
+	Statuses []models.TaskStatus `json:"statuses"`
+	// From и To ограничивают время последнего изменения статуса (ActivityAt)
+	From    *time.Time `json:"from"`
+	To      *time.Time `json:"to"`
+	Profile string     `json:"profile"`
+	// Path - подстрока пути исходного или выходного файла без учета регистра
+	Path string `json:"path"`
+	// Sort - created (по умолчанию), activity, name или size; Asc - по возрастанию
+	Sort string `json:"sort"`
+	Asc  bool   `json:"asc"`
+	// Cursor - nextCursor предыдущей страницы
+	Cursor string `json:"cursor"`
+	Limit  int    `json:"limit"`
+}
+
+// TaskPage - страница списка задач
+type TaskPage struct {
+	Tasks []*models.Task `json:"tasks"`
+	// NextCursor - курсор следующей страницы, пусто на последней
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Total - сколько задач подходит под фильтр без учета страниц
+	Total int `json:"total"`
+}
+
+// Normalize проверяет сортировку и ограничивает размер страницы
+func (q *TaskQuery) Normalize() error {
+	switch q.Sort {
+	case "":
+		q.Sort = SortCreated
+	case SortCreated, SortActivity, SortName, SortSize:
+	default:
+		return fmt.Errorf("неизвестная сортировка %q", q.Sort)
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	return nil
+}
+
+// matches проверяет задачу по фильтрам запроса
+func (q *TaskQuery) matches(task *models.Task) bool {
+	if len(q.Statuses) > 0 {
+		found := false
+		for _, status := range q.Statuses {
+			if task.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if q.From != nil || q.To != nil {
+		at := task.ActivityAt()
+		if q.From != nil && at.Before(*q.From) {
+			return false
+		}
+		if q.To != nil && at.After(*q.To) {
+			return false
+		}
+	}
+
+	if q.Profile != "" && task.Profile != q.Profile {
+		return false
+	}
+
+	if q.Path != "" {
+		needle := strings.ToLower(q.Path)
+		if !strings.Contains(strings.ToLower(task.FilePath), needle) &&
+			!strings.Contains(strings.ToLower(task.OutputPath), needle) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortKey возвращает ключ сортировки задачи в виде строки, которая сравнивается
+// так же, как исходное значение. Ключ вместе с ID задачи образует курсор
+func (q *TaskQuery) sortKey(task *models.Task) string {
+	switch q.Sort {
+	case SortActivity:
+		return fmt.Sprintf("%020d", task.ActivityAt().UnixNano())
+	case SortName:
+		return strings.ToLower(filepath.Base(task.FilePath))
+	case SortSize:
+		return fmt.Sprintf("%020d", task.SourceSize)
+	default:
+		return fmt.Sprintf("%020d", task.CreatedAt.UnixNano())
+	}
+}
+
+// before сравнивает задачи по ключу сортировки и ID в порядке запроса
+func (q *TaskQuery) before(keyA, idA, keyB, idB string) bool {
+	if keyA != keyB {
+		return (keyA < keyB) == q.Asc
+	}
+	if idA == idB {
+		return false
+	}
+	return (idA < idB) == q.Asc
+}
+
+// encodeCursor записывает позицию последней задачи страницы
+func encodeCursor(key, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key + "\x00" + id))
+}
+
+// decodeCursor читает позицию из курсора
+func decodeCursor(cursor string) (string, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", fmt.Errorf("некорректный курсор")
+	}
+	key, id, found := strings.Cut(string(raw), "\x00")
+	if !found {
+		return "", "", fmt.Errorf("некорректный курсор")
+	}
+	return key, id, nil
+}
+
+// ListTasks возвращает страницу задач. Курсор указывает на позицию, а не на номер
+// страницы, поэтому новые задачи не сдвигают уже полученные страницы
+func (s *JSONStore) ListTasks(query TaskQuery) (*TaskPage, error) {
+	if err := query.Normalize(); err != nil {
+		return nil, err
+	}
+
+	var afterKey, afterID string
+	if query.Cursor != "" {
+		var err error
+		if afterKey, afterID, err = decodeCursor(query.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	type entry struct {
+		key  string
+		task *models.Task
+	}
+
+	s.mu.RLock()
+	var entries []entry
+	for _, task := range s.tasks {
+		if query.matches(task) {
+			entries = append(entries, entry{key: query.sortKey(task), task: task})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return query.before(entries[i].key, entries[i].task.ID, entries[j].key, entries[j].task.ID)
+	})
+
+	start := 0
+	if query.Cursor != "" {
+		start = sort.Search(len(entries), func(i int) bool {
+			return query.before(afterKey, afterID, entries[i].key, entries[i].task.ID)
+		})
+	}
+
+	page := &TaskPage{Tasks: []*models.Task{}, Total: len(entries)}
+	end := start + query.Limit
+	if end > len(entries) {
+		end = len(entries)
+	}
+	for _, e := range entries[start:end] {
+		page.Tasks = append(page.Tasks, e.task)
+	}
+	if end < len(entries) {
+		last := entries[end-1]
+		page.NextCursor = encodeCursor(last.key, last.task.ID)
+	}
+
+	return page, nil
+}
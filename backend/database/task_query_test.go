@@ -0,0 +1,224 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// newTestStore создает хранилище с задачами, созданными по минуте друг за другом
+func newTestStore(t *testing.T, count int) (*JSONStore, time.Time) {
+	t.Helper()
+	store, err := NewJSONStore(filepath.Join(t.TempDir(), "tasks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < count; i++ {
+		addTestTask(t, store, base.Add(time.Duration(i)*time.Minute), fmt.Sprintf("/media/movie-%02d.mkv", i), int64(i%3))
+	}
+	return store, base
+}
+
+func addTestTask(t *testing.T, store *JSONStore, createdAt time.Time, filePath string, size int64) *models.Task {
+	t.Helper()
+	finishedAt := createdAt.Add(time.Hour)
+	task := &models.Task{
+		ID:         models.TaskIDAt(createdAt),
+		FilePath:   filePath,
+		Status:     models.StatusCompleted,
+		SourceSize: size,
+		CreatedAt:  createdAt,
+		FinishedAt: &finishedAt,
+	}
+	if err := store.CreateTask(task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+// listAll проходит все страницы запроса и возвращает идентификаторы по порядку
+func listAll(t *testing.T, store *JSONStore, query TaskQuery) []string {
+	t.Helper()
+	var ids []string
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatal("pagination does not terminate")
+		}
+		page, err := store.ListTasks(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, task := range page.Tasks {
+			ids = append(ids, task.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func TestListTasksPagesMatchFullList(t *testing.T) {
+	store, _ := newTestStore(t, 11)
+
+	tests := []struct {
+		name  string
+		query TaskQuery
+	}{
+		{"created desc", TaskQuery{}},
+		{"created asc", TaskQuery{Asc: true}},
+		{"activity desc", TaskQuery{Sort: SortActivity}},
+		{"name asc", TaskQuery{Sort: SortName, Asc: true}},
+		// Размеры повторяются, порядок внутри одного размера задает ID
+		{"size desc", TaskQuery{Sort: SortSize}},
+		{"size asc", TaskQuery{Sort: SortSize, Asc: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			full := tt.query
+			full.Limit = MaxPageSize
+			want := listAll(t, store, full)
+			if len(want) != 11 {
+				t.Fatalf("full list has %d tasks, want 11", len(want))
+			}
+
+			for _, limit := range []int{1, 2, 3, 5, 11} {
+				paged := tt.query
+				paged.Limit = limit
+				if got := listAll(t, store, paged); !equalStrings(got, want) {
+					t.Errorf("limit %d: got %v, want %v", limit, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestListTasksCursorStableWithInserts(t *testing.T) {
+	store, base := newTestStore(t, 9)
+
+	all := listAll(t, store, TaskQuery{Limit: MaxPageSize})
+	first, err := store.ListTasks(TaskQuery{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Между страницами появляются новые задачи и задача со временем создания
+	// внутри уже пройденной части списка
+	var inserted []string
+	inserted = append(inserted, addTestTask(t, store, base.Add(time.Hour), "/media/new-1.mkv", 0).ID)
+	inserted = append(inserted, addTestTask(t, store, base.Add(2*time.Hour), "/media/new-2.mkv", 0).ID)
+	older := addTestTask(t, store, base.Add(7*time.Minute+30*time.Second), "/media/between.mkv", 0)
+
+	rest := listAll(t, store, TaskQuery{Limit: 3, Cursor: first.NextCursor})
+
+	var got []string
+	for _, task := range first.Tasks {
+		got = append(got, task.ID)
+	}
+	for _, id := range rest {
+		if id == older.ID {
+			// Задача внутри пройденной части не должна появиться на следующих страницах
+			t.Errorf("task inserted before the cursor %s appeared on a later page", id)
+			continue
+		}
+		got = append(got, id)
+	}
+	if !equalStrings(got, all) {
+		t.Errorf("pages after inserts = %v, want %v", got, all)
+	}
+	for _, id := range inserted {
+		for _, seen := range rest {
+			if seen == id {
+				t.Errorf("newer task %s appeared after the cursor", id)
+			}
+		}
+	}
+}
+
+func TestListTasksCursorAfterInsertBelowCursor(t *testing.T) {
+	store, base := newTestStore(t, 6)
+
+	first, err := store.ListTasks(TaskQuery{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Задача старше курсора должна попасть на следующие страницы ровно один раз
+	older := addTestTask(t, store, base.Add(90*time.Second), "/media/older.mkv", 0)
+
+	count := 0
+	for _, id := range listAll(t, store, TaskQuery{Limit: 2, Cursor: first.NextCursor}) {
+		if id == older.ID {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("task inserted after the cursor seen %d times, want 1", count)
+	}
+}
+
+func TestListTasksInvalidCursor(t *testing.T) {
+	store, _ := newTestStore(t, 1)
+
+	// Не base64 и base64 без разделителя ключа и ID
+	for _, cursor := range []string{"%%%", "bm8tc2VwYXJhdG9y"} {
+		if _, err := store.ListTasks(TaskQuery{Cursor: cursor}); err == nil {
+			t.Errorf("ListTasks(cursor %q) succeeded, want error", cursor)
+		}
+	}
+	if _, err := store.ListTasks(TaskQuery{Sort: "unknown"}); err == nil {
+		t.Error("ListTasks(sort unknown) succeeded, want error")
+	}
+}
+
+func TestGetTasksOrderWithEqualCreatedAt(t *testing.T) {
+	store, err := NewJSONStore(filepath.Join(t.TempDir(), "tasks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Три задачи созданы в одну секунду, порядок между ними задает ID
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ids := []string{"01HQ0000000000000000000003", "01HQ0000000000000000000001", "01HQ0000000000000000000002"}
+	for i, id := range ids {
+		task := &models.Task{ID: id, FilePath: fmt.Sprintf("/media/%d.mkv", i), Status: models.StatusPending, CreatedAt: createdAt}
+		if err := store.CreateTask(task); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addTestTask(t, store, createdAt.Add(-time.Minute), "/media/older.mkv", 0)
+	older := "01HQ0000000000000000000000"
+	if err := store.CreateTask(&models.Task{ID: older, FilePath: "/media/older-pending.mkv", Status: models.StatusPending,
+		CreatedAt: createdAt.Add(-time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := store.GetPendingTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := idsOf(pending), []string{older, ids[1], ids[2], ids[0]}; !equalStrings(got, want) {
+		t.Errorf("GetPendingTasks() = %v, want %v", got, want)
+	}
+
+	all, err := store.GetAllTasks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Новые первыми, последняя - завершенная задача на минуту старше
+	if got, want := idsOf(all)[:4], []string{ids[0], ids[2], ids[1], older}; !equalStrings(got, want) {
+		t.Errorf("GetAllTasks() = %v, want %v first", idsOf(all), want)
+	}
+}
+
+func idsOf(tasks []*models.Task) []string {
+	var ids []string
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"ultimate-dts-fix-server/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleArrWebhook принимает webhook Sonarr/Radarr и ставит импортированные файлы в очередь
+func (h *Handler) handleArrWebhook(c *gin.Context) {
+	if !h.arrAuthorized(c) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	var payload services.ArrPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch payload.EventType {
+	case services.ArrEventTest:
+		c.JSON(http.StatusOK, gin.H{"message": "ok"})
+	case services.ArrEventDownload:
+		c.JSON(http.StatusOK, h.arrService.ImportFiles(&payload))
+	default:
+		// Остальные события (Grab, Rename, удаление) не требуют конвертации
+		c.JSON(http.StatusOK, gin.H{"message": "ignored", "eventType": payload.EventType})
+	}
+}
+
+// arrAuthorized проверяет ключ приема webhooks *arr
+func (h *Handler) arrAuthorized(c *gin.Context) bool {
+	return tokenAuthorized(c, h.cfg.Arr.Token)
+}
+
+// tokenAuthorized проверяет ключ из параметра token или пароля Basic авторизации
+func tokenAuthorized(c *gin.Context, expected string) bool {
+	token := c.Query("token")
+	if token == "" {
+		_, token, _ = c.Request.BasicAuth()
+	}
+	return token != "" && expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+	"ultimate-dts-fix-server/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleBundleExport отдает выгрузку очереди, истории и архива. Параметр config=1 добавляет
+// настройки без паролей и ключей, archive=0 исключает архив
+func (h *Handler) handleBundleExport(c *gin.Context) {
+	opts := services.ExportOptions{
+		Config:  c.Query("config") == "1",
+		Archive: c.Query("archive") != "0",
+	}
+
+	filename := fmt.Sprintf("dts-fix-bundle-%s.ndjson", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// Заголовки уже отправлены, ошибку можно только записать в лог
+	if err := h.bundleService.Export(c.Writer, opts); err != nil {
+		log.Printf("Ошибка выгрузки данных: %v", err)
+	}
+}
+
+// handleBundleImport загружает выгрузку из тела запроса. Параметры: remap=/старый=/новый
+// (можно несколько), conflict=skip|overwrite|newid, config=1 - заменить настройки, dryRun=1 - только итог
+func (h *Handler) handleBundleImport(c *gin.Context) {
+	opts := services.ImportOptions{
+		Conflict: c.Query("conflict"),
+		Config:   c.Query("config") == "1",
+		DryRun:   c.Query("dryRun") == "1",
+	}
+	for _, value := range c.QueryArray("remap") {
+		mapping, err := services.ParsePathMapping(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		opts.Remap = append(opts.Remap, mapping)
+	}
+
+	summary, err := h.bundleService.Import(c.Request.Body, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
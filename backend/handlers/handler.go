@@ -4,24 +4,35 @@ import (
 	"embed"
 	"io/fs"
 	"net/http"
+	"ultimate-dts-fix-server/backend/config"
 	"ultimate-dts-fix-server/backend/services"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Handler struct {
-	wsService   *services.WebSocketService
-	staticFiles embed.FS
+	wsService      *services.WebSocketService
+	arrService     *services.ArrService
+	historyService *services.HistoryService
+	bundleService  *services.BundleService
+	statsService   *services.StatsService
+	cfg            *config.Config
+	staticFiles    embed.FS
 }
 
-func NewHandler(queueService *services.QueueService, converterService *services.ConverterService, wsService *services.WebSocketService, staticFiles embed.FS) *Handler {
+func NewHandler(queueService *services.QueueService, converterService *services.ConverterService, wsService *services.WebSocketService, arrService *services.ArrService, historyService *services.HistoryService, bundleService *services.BundleService, statsService *services.StatsService, cfg *config.Config, staticFiles embed.FS) *Handler {
 	// Устанавливаем связи между сервисами
 	queueService.SetWebSocketService(wsService)
 	converterService.SetWebSocketService(wsService)
 
 	return &Handler{
-		wsService:   wsService,
-		staticFiles: staticFiles,
+		wsService:      wsService,
+		arrService:     arrService,
+		historyService: historyService,
+		bundleService:  bundleService,
+		statsService:   statsService,
+		cfg:            cfg,
+		staticFiles:    staticFiles,
 	}
 }
 
@@ -37,11 +48,27 @@ func (h *Handler) setupRouter() *gin.Engine {
 
 	router := gin.Default()
 
-	// WebSocket endpoint - единственный API endpoint
+	// WebSocket endpoint - основной API
 	router.GET("/ws", func(c *gin.Context) {
 		h.wsService.HandleWebSocket(c.Writer, c.Request)
 	})
 
+	// Входящие webhooks Sonarr/Radarr
+	if h.cfg.Arr.Enabled {
+		router.POST("/api/arr/webhook", h.handleArrWebhook)
+	}
+
+	// Выгрузка архива истории задач, только с ключом adminToken
+	router.GET("/api/history/archive", h.requireAdmin, h.handleHistoryArchive)
+
+	// Статистика конвертаций для встроенного интерфейса
+	router.GET("/api/stats", h.handleStats)
+
+	// Перенос очереди, истории и настроек на другой сервер, только с ключом adminToken
+	bundle := router.Group("/api/bundle", h.requireAdmin)
+	bundle.GET("/export", h.handleBundleExport)
+	bundle.POST("/import", h.handleBundleImport)
+
 	// Встроенные статические файлы
 	staticFS, err := fs.Sub(h.staticFiles, "static")
 	if err != nil {
@@ -61,3 +88,16 @@ func (h *Handler) setupRouter() *gin.Engine {
 
 	return router
 }
+
+// requireAdmin пропускает только запросы с ключом adminToken
+func (h *Handler) requireAdmin(c *gin.Context) {
+	if h.cfg.AdminToken == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "adminToken is not configured"})
+		return
+	}
+	if !tokenAuthorized(c, h.cfg.AdminToken) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleHistoryArchive отдает архив истории tasks-archive.jsonl.gz как есть
+func (h *Handler) handleHistoryArchive(c *gin.Context) {
+	file, err := h.historyService.OpenArchive()
+	if os.IsNotExist(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "archive is empty"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Размер фиксируем на момент открытия: дописанный позже блок не попадет в ответ
+	c.DataFromReader(http.StatusOK, info.Size(), "application/gzip", io.LimitReader(file, info.Size()), map[string]string{
+		"Content-Disposition": `attachment; filename="tasks-archive.jsonl.gz"`,
+	})
+}
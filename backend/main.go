@@ -4,6 +4,7 @@ import (
 	"embed"
 	"log"
 	"os"
+	"ultimate-dts-fix-server/backend/config"
 	"ultimate-dts-fix-server/backend/database"
 	"ultimate-dts-fix-server/backend/handlers"
 	"ultimate-dts-fix-server/backend/services"
@@ -13,6 +14,12 @@ import (
 var staticFiles embed.FS
 
 func main() {
+	// Загрузка конфигурации
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Ошибка загрузки конфигурации:", err)
+	}
+
 	// Инициализация хранилища данных
 	db, err := database.InitDB()
 	if err != nil {
@@ -22,20 +29,60 @@ func main() {
 
 	// Инициализация сервисов
 	queueService := services.NewQueueService(db)
-	converterService := services.NewConverterService(queueService)
+	converterService := services.NewConverterService(queueService, cfg)
+	notifierService := services.NewNotifierService(cfg.Notifiers, queueService)
+	webhookService := services.NewWebhookService(cfg.Webhooks, db)
 	wsService := services.NewWebSocketService()
+	probeCache := services.NewProbeCache(db.Inventory(), cfg.Scan.CheckInode)
+	libraryScanner := services.NewLibraryScanner(cfg.MediaRoots, cfg.Search, cfg.Scan.Workers, db, probeCache)
+	historyService := services.NewHistoryService(cfg.History, db)
+	bundleService := services.NewBundleService(cfg, db, queueService)
+	statsService := services.NewStatsService(db)
+	arrService := services.NewArrService(cfg.Arr, queueService, converterService)
 
 	// Установка связей между сервисами
 	queueService.SetWebSocketService(wsService)
 	converterService.SetWebSocketService(wsService)
+	converterService.SetNotifierService(notifierService)
+	converterService.SetProbeCache(probeCache)
+	converterService.SetWebhookService(webhookService)
+	queueService.SetWebhookService(webhookService)
+	queueService.SetConverterService(converterService)
+	notifierService.SetWebSocketService(wsService)
 	wsService.SetServices(queueService, converterService)
+	wsService.SetNotifierService(notifierService)
+	libraryScanner.SetWebSocketService(wsService)
+	wsService.SetLibraryScanner(libraryScanner)
+	wsService.SetSearchConfig(cfg.MediaRoots, cfg.Search)
+	wsService.SetWebhookService(webhookService)
+	historyService.SetWebSocketService(wsService)
+	wsService.SetHistoryService(historyService)
+	bundleService.SetWebSocketService(wsService)
+	wsService.SetStatsService(statsService)
+	arrService.SetWebSocketService(wsService)
+
+	// Публикация состояния в MQTT для Home Assistant
+	if cfg.MQTT.Enabled {
+		mqttService := services.NewMQTTService(cfg.MQTT, queueService)
+		wsService.SetMQTTService(mqttService)
+		mqttService.Start()
+	}
+
+	// Почтовые уведомления об ошибках и ежедневная сводка
+	if cfg.Email.Enabled {
+		emailService := services.NewEmailService(cfg.Email, db)
+		converterService.SetEmailService(emailService)
+		wsService.SetEmailService(emailService)
+		go emailService.Start()
+	}
 
 	// Запуск сервисов
 	go queueService.Start()
 	go converterService.Start()
+	go historyService.Start()
 
 	// Инициализация обработчиков HTTP
-	handler := handlers.NewHandler(queueService, converterService, wsService, staticFiles)
+	handler := handlers.NewHandler(queueService, converterService, wsService, arrService, historyService, bundleService, statsService, cfg, staticFiles)
 
 	// Запуск HTTP сервера
 	port := getPort()
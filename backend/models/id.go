@@ -0,0 +1,89 @@
+package models
+
+import (
+	"crypto/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// crockford - алфавит base32 Crockford, в котором записываются идентификаторы задач
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// taskIDLength - длина идентификатора: 48 бит времени и 80 случайных бит
+const taskIDLength = 26
+
+var (
+	lastIDTime    int64
+	lastIDEntropy [10]byte
+	idMu          sync.Mutex
+)
+
+// NewTaskID возвращает новый идентификатор задачи в формате ULID
+func NewTaskID() string {
+	return TaskIDAt(time.Now())
+}
+
+// TaskIDAt возвращает идентификатор в формате ULID для момента t. Идентификаторы
+// сортируются строкой по времени, а внутри одной миллисекунды - по порядку выдачи
+func TaskIDAt(t time.Time) string {
+	idMu.Lock()
+	defer idMu.Unlock()
+
+	ms := t.UnixMilli()
+	if ms == lastIDTime {
+		// Увеличиваем случайную часть предыдущего идентификатора на единицу
+		for i := len(lastIDEntropy) - 1; i >= 0; i-- {
+			lastIDEntropy[i]++
+			if lastIDEntropy[i] != 0 {
+				break
+			}
+		}
+	} else {
+		lastIDTime = ms
+		if _, err := rand.Read(lastIDEntropy[:]); err != nil {
+			// Без случайности уникальность держится на монотонном счетчике
+			lastIDEntropy = [10]byte{}
+		}
+	}
+
+	var raw [16]byte
+	for i := 0; i < 6; i++ {
+		raw[i] = byte(uint64(ms) >> (40 - 8*i))
+	}
+	copy(raw[6:], lastIDEntropy[:])
+
+	return encodeCrockford(raw)
+}
+
+// IsTaskID проверяет, что строка - идентификатор в формате ULID
+func IsTaskID(id string) bool {
+	if len(id) != taskIDLength || id[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if !strings.ContainsRune(crockford, rune(id[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeCrockford записывает 128 бит 26 символами base32, по 5 бит начиная со старших
+func encodeCrockford(raw [16]byte) string {
+	var out [taskIDLength]byte
+	// 130 бит вывода: первые 2 бита всегда нулевые
+	bit := -2
+	for i := 0; i < taskIDLength; i++ {
+		value := 0
+		for j := 0; j < 5; j++ {
+			value <<= 1
+			if bit >= 0 && raw[bit/8]&(0x80>>(bit%8)) != 0 {
+				value |= 1
+			}
+			bit++
+		}
+		out[i] = crockford[value]
+	}
+	return string(out[:])
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskIDAtOrdering(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{
+		base,
+		base.Add(time.Millisecond),
+		base.Add(time.Second),
+		base.Add(24 * time.Hour),
+		base.AddDate(10, 0, 0),
+	}
+
+	var previous string
+	for i, at := range times {
+		id := TaskIDAt(at)
+		if !IsTaskID(id) {
+			t.Fatalf("TaskIDAt(%v) = %q, not a task ID", at, id)
+		}
+		if i > 0 && id <= previous {
+			t.Errorf("TaskIDAt(%v) = %q, want greater than %q", at, id, previous)
+		}
+		previous = id
+	}
+}
+
+func TestTaskIDAtMonotonicWithinMillisecond(t *testing.T) {
+	at := time.Date(2024, 5, 2, 8, 30, 0, 0, time.UTC)
+
+	first := TaskIDAt(at)
+	previous := first
+	for i := 0; i < 1000; i++ {
+		// Наносекунды внутри той же миллисекунды не меняют время идентификатора
+		id := TaskIDAt(at.Add(time.Duration(i) * time.Microsecond / 10))
+		if id <= previous {
+			t.Fatalf("id %d = %q, want greater than %q", i, id, previous)
+		}
+		if id[:10] != first[:10] {
+			t.Fatalf("id %d = %q, time part changed from %q", i, id, first[:10])
+		}
+		previous = id
+	}
+}
+
+func TestTaskIDAtTimePart(t *testing.T) {
+	tests := []struct {
+		ms   int64
+		want string
+	}{
+		{0, "0000000000"},
+		// Пример из спецификации ULID: 01ARYZ6S41TSV4RRFFQ69G5FAV
+		{1469918176385, "01ARYZ6S41"},
+		{1<<48 - 1, "7ZZZZZZZZZ"},
+	}
+
+	for _, tt := range tests {
+		id := TaskIDAt(time.UnixMilli(tt.ms))
+		if got := id[:10]; got != tt.want {
+			t.Errorf("TaskIDAt(%d) time part = %q, want %q", tt.ms, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeCrockford(t *testing.T) {
+	var ones [16]byte
+	for i := range ones {
+		ones[i] = 0xFF
+	}
+	var low [16]byte
+	low[15] = 0x1F
+
+	tests := []struct {
+		name string
+		raw  [16]byte
+		want string
+	}{
+		{"zero", [16]byte{}, strings.Repeat("0", 26)},
+		{"all bits", ones, "7" + strings.Repeat("Z", 25)},
+		{"lowest five bits", low, strings.Repeat("0", 25) + "Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeCrockford(tt.raw); got != tt.want {
+				t.Errorf("encodeCrockford() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTaskID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"01ARYZ6S41TSV4RRFFQ69G5FAV", true},
+		{"7ZZZZZZZZZZZZZZZZZZZZZZZZZ", true},
+		{NewTaskID(), true},
+		{"", false},
+		{"20240102150405", false},
+		{"01ARYZ6S41TSV4RRFFQ69G5FA", false},
+		{"01ARYZ6S41TSV4RRFFQ69G5FAVX", false},
+		{"01aryz6s41tsv4rrffq69g5fav", false},
+		// Первый символ больше 7 не помещается в 128 бит
+		{"81ARYZ6S41TSV4RRFFQ69G5FAV", false},
+		// I, L, O и U не входят в алфавит Crockford
+		{"01ARYZ6S41TSV4RRFFQ69G5FAI", false},
+		{"01ARYZ6S41TSV4RRFFQ69G5FAL", false},
+		{"01ARYZ6S41TSV4RRFFQ69G5FAO", false},
+		{"01ARYZ6S41TSV4RRFFQ69G5FAU", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTaskID(tt.id); got != tt.want {
+			t.Errorf("IsTaskID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// MediaFile - видеофайл медиатеки с описанием всех аудиодорожек.
+// Size, ModTime и Inode определяют, актуальна ли запись для файла на диске
+type MediaFile struct {
+	Path       string        `json:"path"`
+	Size       int64         `json:"size"`
+	ModTime    time.Time     `json:"modTime"`
+	Inode      uint64        `json:"inode,omitempty"`
+	Duration   float64       `json:"duration,omitempty"`
+	Audio      []AudioStream `json:"audio"`
+	ProbeError string        `json:"probeError,omitempty"`
+	ScannedAt  time.Time     `json:"scannedAt"`
+}
+
+// AudioStream - аудиодорожка по данным ffprobe
+type AudioStream struct {
+	Index         int    `json:"index"` // Номер дорожки среди аудио (a:N)
+	CodecName     string `json:"codecName"`
+	Profile       string `json:"profile,omitempty"` // Например DTS-HD MA
+	ChannelLayout string `json:"channelLayout,omitempty"`
+	Channels      int    `json:"channels"`
+	SampleRate    string `json:"sampleRate,omitempty"`
+	BitRate       string `json:"bitRate,omitempty"`
+	Language      string `json:"language,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Default       bool   `json:"default,omitempty"`
+}
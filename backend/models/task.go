@@ -9,8 +9,22 @@ type TaskStatus string
 const (
 	StatusPending    TaskStatus = "pending"
 	StatusProcessing TaskStatus = "processing"
+	StatusPaused     TaskStatus = "paused"
 	StatusCompleted  TaskStatus = "completed"
 	StatusError      TaskStatus = "error"
+	StatusSkipped    TaskStatus = "skipped"
+	StatusReverted   TaskStatus = "reverted"
+)
+
+// Типы ошибок задач. Сохраняются вместе с задачей, чтобы статистика не зависела от текста ошибки
+const (
+	ErrorTypeCancelled    = "cancelled"
+	ErrorTypeDiskSpace    = "disk_space"
+	ErrorTypeVerification = "verification"
+	ErrorTypeProbe        = "ffprobe"
+	ErrorTypeFFmpeg       = "ffmpeg"
+	ErrorTypeFinalize     = "finalize"
+	ErrorTypeOther        = "other"
 )
 
 type AudioInfo struct {
@@ -21,17 +35,95 @@ type AudioInfo struct {
 	BitRate       string `json:"bitRate"`
 }
 
+// SidecarFile - сопутствующий файл, переименованный или скопированный под имя результата
+type SidecarFile struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Copied bool   `json:"copied,omitempty"`
+}
+
+// NotificationStatus - результат уведомления медиасервера о задаче
+type NotificationStatus struct {
+	Notifier string    `json:"notifier"`
+	Status   string    `json:"status"` // pending, ok или failed
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+	At       time.Time `json:"at"`
+}
+
 type Task struct {
-	ID          string     `json:"id"`
-	FilePath    string     `json:"filePath"`
-	OutputPath  string     `json:"outputPath"`
-	Status      TaskStatus `json:"status"`
-	Progress    int        `json:"progress"`
-	Error       string     `json:"error,omitempty"`
-	AudioInfo   *AudioInfo `json:"audioInfo,omitempty"`
-	Duration    float64    `json:"duration,omitempty"`    // Длительность видео в секундах
-	CurrentTime float64    `json:"currentTime,omitempty"` // Текущее время конвертации в секундах
-	CreatedAt   time.Time  `json:"createdAt"`
-	StartedAt   *time.Time `json:"startedAt,omitempty"`
-	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	ID            string               `json:"id"`
+	LegacyID      string               `json:"legacyId,omitempty"` // Идентификатор до перехода на ULID
+	FilePath      string               `json:"filePath"`
+	OutputPath    string               `json:"outputPath"`
+	Profile       string               `json:"profile,omitempty"`       // Имя профиля конвертации
+	TempPath      string               `json:"tempPath,omitempty"`      // Временный файл, в который пишет FFmpeg
+	BackupPath    string               `json:"backupPath,omitempty"`    // Куда перенесен исходный файл (.bak или карантин)
+	Finalize      string               `json:"finalize,omitempty"`      // Примененная политика обработки исходного файла
+	Sidecars      []SidecarFile        `json:"sidecars,omitempty"`      // Сопутствующие файлы, перенесенные под имя результата
+	Notifications []NotificationStatus `json:"notifications,omitempty"` // Уведомления медиасерверов
+	Status        TaskStatus           `json:"status"`
+	Progress      int                  `json:"progress"`
+	Error         string               `json:"error,omitempty"`
+	ErrorType     string               `json:"errorType,omitempty"`  // Тип ошибки, одна из констант ErrorType*
+	HoldReason    string               `json:"holdReason,omitempty"` // Почему задача ожидает запуска
+	AudioInfo     *AudioInfo           `json:"audioInfo,omitempty"`
+	SourceSize    int64                `json:"sourceSize,omitempty"`    // Размер исходного файла в байтах
+	EstimatedSize int64                `json:"estimatedSize,omitempty"` // Оценка размера выходного файла в байтах
+	OutputSize    int64                `json:"outputSize,omitempty"`    // Фактический размер выходного файла в байтах
+	Duration      float64              `json:"duration,omitempty"`      // Длительность видео в секундах
+	CurrentTime   float64              `json:"currentTime,omitempty"`   // Текущее время конвертации в секундах
+	Elapsed       float64              `json:"elapsed,omitempty"`       // Время работы в секундах без учета пауз
+	PausedSeconds float64              `json:"pausedSeconds,omitempty"` // Суммарная длительность завершенных пауз в секундах
+	CreatedAt     time.Time            `json:"createdAt"`
+	StartedAt     *time.Time           `json:"startedAt,omitempty"`
+	PausedAt      *time.Time           `json:"pausedAt,omitempty"`
+	CompletedAt   *time.Time           `json:"completedAt,omitempty"`
+	FinishedAt    *time.Time           `json:"finishedAt,omitempty"` // Переход в конечный статус: завершена, ошибка или пропущена
+	RevertedAt    *time.Time           `json:"revertedAt,omitempty"`
+}
+
+// FinishedStatuses - конечные статусы, задачи в них показываются в истории
+var FinishedStatuses = []TaskStatus{StatusCompleted, StatusError, StatusSkipped, StatusReverted}
+
+// IsActive возвращает true для задач, которые еще находятся в очереди
+func (t *Task) IsActive() bool {
+	return t.Status == StatusPending || t.Status == StatusProcessing || t.Status == StatusPaused
+}
+
+// ActivityAt возвращает время последнего изменения статуса задачи
+func (t *Task) ActivityAt() time.Time {
+	switch {
+	case t.RevertedAt != nil:
+		return *t.RevertedAt
+	case t.FinishedAt != nil:
+		return *t.FinishedAt
+	case t.CompletedAt != nil:
+		return *t.CompletedAt
+	case t.StartedAt != nil:
+		return *t.StartedAt
+	}
+	return t.CreatedAt
+}
+
+// ElapsedAt вычисляет время работы задачи на момент now без учета пауз
+func (t *Task) ElapsedAt(now time.Time) float64 {
+	if t.StartedAt == nil {
+		return 0
+	}
+
+	end := now
+	if t.CompletedAt != nil {
+		end = *t.CompletedAt
+	}
+
+	elapsed := end.Sub(*t.StartedAt).Seconds() - t.PausedSeconds
+	if t.PausedAt != nil {
+		elapsed -= end.Sub(*t.PausedAt).Seconds()
+	}
+
+	if elapsed < 0 {
+		return 0
+	}
+	return elapsed
 }
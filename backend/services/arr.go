@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"ultimate-dts-fix-server/backend/config"
+)
+
+const (
+	// ArrEventDownload - событие On Import / On Upgrade в Sonarr и Radarr
+	ArrEventDownload = "Download"
+	// ArrEventTest - проверка webhook из настроек *arr
+	ArrEventTest = "Test"
+)
+
+// ArrPayload - webhook Sonarr/Radarr, разбираются только поля с путями файлов
+type ArrPayload struct {
+	EventType    string `json:"eventType"`
+	InstanceName string `json:"instanceName"`
+	Series       *struct {
+		Path string `json:"path"`
+	} `json:"series"`
+	Movie *struct {
+		FolderPath string `json:"folderPath"`
+	} `json:"movie"`
+	EpisodeFile  *ArrFile  `json:"episodeFile"`
+	EpisodeFiles []ArrFile `json:"episodeFiles"`
+	MovieFile    *ArrFile  `json:"movieFile"`
+}
+
+// ArrFile - импортированный файл
+type ArrFile struct {
+	Path         string `json:"path"`
+	RelativePath string `json:"relativePath"`
+}
+
+// ArrImportResult - итог обработки webhook
+type ArrImportResult struct {
+	Added   []ArrImportedFile `json:"added"`
+	Skipped []ArrSkippedFile  `json:"skipped"`
+}
+
+// ArrImportedFile - файл, поставленный в очередь
+type ArrImportedFile struct {
+	TaskID   string `json:"taskId"`
+	FilePath string `json:"filePath"`
+}
+
+// ArrSkippedFile - файл, не попавший в очередь, с причиной
+type ArrSkippedFile struct {
+	FilePath string `json:"filePath"`
+	Reason   string `json:"reason"`
+}
+
+// FilePaths возвращает пути импортированных файлов так, как их видит *arr.
+// Если полный путь не передан, он собирается из директории сериала/фильма
+func (p *ArrPayload) FilePaths() []string {
+	var files []ArrFile
+	if p.EpisodeFile != nil {
+		files = append(files, *p.EpisodeFile)
+	}
+	files = append(files, p.EpisodeFiles...)
+	if p.MovieFile != nil {
+		files = append(files, *p.MovieFile)
+	}
+
+	root := ""
+	if p.Series != nil {
+		root = p.Series.Path
+	} else if p.Movie != nil {
+		root = p.Movie.FolderPath
+	}
+
+	seen := make(map[string]bool)
+	var paths []string
+	for _, file := range files {
+		path := file.Path
+		if path == "" && root != "" && file.RelativePath != "" {
+			path = filepath.Join(root, file.RelativePath)
+		}
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// ArrService ставит в очередь файлы из webhooks Sonarr/Radarr
+type ArrService struct {
+	cfg              config.ArrConfig
+	queueService     *QueueService
+	converterService *ConverterService
+	wsService        *WebSocketService
+}
+
+func NewArrService(cfg config.ArrConfig, queueService *QueueService, converterService *ConverterService) *ArrService {
+	return &ArrService{
+		cfg:              cfg,
+		queueService:     queueService,
+		converterService: converterService,
+	}
+}
+
+// SetWebSocketService устанавливает WebSocket сервис для логов
+func (s *ArrService) SetWebSocketService(wsService *WebSocketService) {
+	s.wsService = wsService
+}
+
+// ImportFiles ставит в очередь файлы из webhook Sonarr/Radarr с теми же проверками,
+// что и команда add_task, и дополнительно фильтрует их по аудиокодеку
+func (s *ArrService) ImportFiles(payload *ArrPayload) *ArrImportResult {
+	result := &ArrImportResult{
+		Added:   []ArrImportedFile{},
+		Skipped: []ArrSkippedFile{},
+	}
+
+	source := payload.InstanceName
+	if source == "" {
+		source = "Sonarr/Radarr"
+	}
+
+	for _, arrPath := range payload.FilePaths() {
+		filePath := filepath.FromSlash(config.MapPath(arrPath, s.cfg.PathMap))
+
+		task, err := s.converterService.prepareTask(filePath, s.cfg.Profile)
+		if err == nil && !s.cfg.AcceptsCodec(task.AudioInfo.CodecName) {
+			err = fmt.Errorf("аудиокодек %s не входит в arr.codecs", task.AudioInfo.CodecName)
+		}
+		if err != nil {
+			log.Printf("Файл из %s не добавлен: %s: %v", source, filePath, err)
+			result.Skipped = append(result.Skipped, ArrSkippedFile{FilePath: filePath, Reason: err.Error()})
+			continue
+		}
+
+		// Импорт - это новый файл, даже если по тому же пути раньше лежал сконвертированный,
+		// поэтому проверяются только незавершенные задачи
+		if err := s.queueService.Enqueue(task, true); err != nil {
+			log.Printf("Файл из %s не добавлен: %s: %v", source, filePath, err)
+			result.Skipped = append(result.Skipped, ArrSkippedFile{FilePath: filePath, Reason: err.Error()})
+			continue
+		}
+		message := fmt.Sprintf("Задача добавлена из %s: %s", source, filePath)
+		log.Println(message)
+		if s.wsService != nil {
+			s.wsService.BroadcastLog(message, "info")
+		}
+		result.Added = append(result.Added, ArrImportedFile{TaskID: task.ID, FilePath: filePath})
+	}
+
+	return result
+}
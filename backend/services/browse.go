@@ -0,0 +1,241 @@
+package services
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// DirectoryEntry - поддиректория или видеофайл в ответе list_directory
+type DirectoryEntry struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size,omitempty"`
+	Modified int64  `json:"modified"`
+	// Audio - первая аудиодорожка из кэша ffprobe, если файл уже читался и не изменился
+	Audio        *models.AudioStream `json:"audio,omitempty"`
+	AudioStreams int                 `json:"audioStreams,omitempty"`
+}
+
+// DirectoryListing - содержимое директории медиатеки
+type DirectoryListing struct {
+	Path string `json:"path"`
+	// Parent - директория уровнем выше, пусто для корня медиатеки
+	Parent    string           `json:"parent,omitempty"`
+	Dirs      []DirectoryEntry `json:"dirs"`
+	Files     []DirectoryEntry `json:"files"`
+	Recursive bool             `json:"recursive,omitempty"`
+	// Truncated - при рекурсивном обходе найдено больше search.maxResults файлов
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// listRoots возвращает медиатеки как директории верхнего уровня
+func (s *WebSocketService) listRoots() *DirectoryListing {
+	listing := &DirectoryListing{Dirs: []DirectoryEntry{}, Files: []DirectoryEntry{}}
+	for _, root := range s.searchRoots {
+		info, err := os.Stat(root)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		listing.Dirs = append(listing.Dirs, DirectoryEntry{
+			Name:     root,
+			Path:     root,
+			Modified: info.ModTime().Unix(),
+		})
+	}
+	return listing
+}
+
+// listDirectory возвращает поддиректории и видеофайлы директории. С recursive
+// возвращаются все видеофайлы поддерева без директорий, не больше search.maxResults
+func (s *WebSocketService) listDirectory(dir string, recursive bool) (*DirectoryListing, error) {
+	dir, root, err := s.resolveMediaDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	listing := &DirectoryListing{
+		Path:      dir,
+		Dirs:      []DirectoryEntry{},
+		Files:     []DirectoryEntry{},
+		Recursive: recursive,
+	}
+	if dir != root {
+		listing.Parent = filepath.Dir(dir)
+	}
+
+	if recursive {
+		err = s.walkVideoFiles(dir, listing)
+	} else {
+		err = s.readVideoDir(dir, listing)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sortEntries(listing.Dirs)
+	if !recursive {
+		sortEntries(listing.Files)
+	}
+	return listing, nil
+}
+
+// readVideoDir читает одну директорию без вложенных
+func (s *WebSocketService) readVideoDir(dir string, listing *DirectoryListing) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать директорию: %v", err)
+	}
+
+	for _, entry := range entries {
+		// Скрытые файлы - в том числе временные результаты FFmpeg
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+
+		// Ссылки на директории показываем как директории
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		if info.IsDir() {
+			if s.searchConfig.Excluded(path) {
+				continue
+			}
+			// Ссылки, ведущие за пределы медиатек, не показываем
+			if _, _, err := s.resolveMediaDir(path); err != nil {
+				continue
+			}
+			listing.Dirs = append(listing.Dirs, DirectoryEntry{
+				Name:     entry.Name(),
+				Path:     path,
+				Modified: info.ModTime().Unix(),
+			})
+		} else if isVideoFile(path) {
+			listing.Files = append(listing.Files, s.fileEntry(path, info))
+		}
+	}
+	return nil
+}
+
+// walkVideoFiles собирает видеофайлы поддерева, пропуская исключенные директории
+func (s *WebSocketService) walkVideoFiles(dir string, listing *DirectoryListing) error {
+	maxResults := s.searchConfig.MaxResults
+	errLimit := fmt.Errorf("достигнуто ограничение")
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if s.searchConfig.Excluded(path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isVideoFile(path) {
+			return nil
+		}
+
+		if maxResults > 0 && len(listing.Files) >= maxResults {
+			listing.Truncated = true
+			return errLimit
+		}
+		if info, err := d.Info(); err == nil {
+			listing.Files = append(listing.Files, s.fileEntry(path, info))
+		}
+		return nil
+	})
+
+	if err != nil && err != errLimit {
+		return fmt.Errorf("ошибка обхода директории: %v", err)
+	}
+	return nil
+}
+
+// fileEntry описывает видеофайл с аудио из кэша ffprobe, сам ffprobe не запускается
+func (s *WebSocketService) fileEntry(path string, info os.FileInfo) DirectoryEntry {
+	entry := DirectoryEntry{
+		Name:     filepath.Base(path),
+		Path:     path,
+		Size:     info.Size(),
+		Modified: info.ModTime().Unix(),
+	}
+
+	if s.converterService == nil {
+		return entry
+	}
+	if cached := s.converterService.probeCache.Cached(path, info); cached != nil {
+		entry.AudioStreams = len(cached.Audio)
+		if len(cached.Audio) > 0 {
+			stream := cached.Audio[0]
+			entry.Audio = &stream
+		}
+	}
+	return entry
+}
+
+// resolveMediaDir проверяет, что путь - директория внутри одной из медиатек,
+// в том числе после раскрытия символических ссылок. Возвращает очищенный путь и его медиатеку
+func (s *WebSocketService) resolveMediaDir(dir string) (string, string, error) {
+	dir = filepath.Clean(dir)
+	if !filepath.IsAbs(dir) {
+		return "", "", fmt.Errorf("ожидается абсолютный путь")
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("директория не найдена: %s", dir)
+	}
+	if !info.IsDir() {
+		return "", "", fmt.Errorf("не директория: %s", dir)
+	}
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, root := range s.searchRoots {
+		if !pathWithin(dir, root) {
+			continue
+		}
+		realRoot, err := filepath.EvalSymlinks(root)
+		if err != nil || !pathWithin(realDir, realRoot) {
+			continue
+		}
+		return dir, root, nil
+	}
+	return "", "", fmt.Errorf("путь вне медиатек: %s", dir)
+}
+
+// pathWithin проверяет, что path совпадает с root или лежит внутри него
+func pathWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// sortEntries упорядочивает записи по имени без учета регистра
+func sortEntries(entries []DirectoryEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
+	})
+}
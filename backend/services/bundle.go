@@ -0,0 +1,578 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/database"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// Файл выгрузки - JSON Lines: первая строка - заголовок, затем настройки, задачи и задачи архива.
+// Версия увеличивается при несовместимых изменениях формата
+const (
+	bundleFormat  = "ultimate-dts-fix-bundle"
+	bundleVersion = 1
+
+	// maxImportSkips - сколько пропущенных задач перечисляется в итоге импорта
+	maxImportSkips = 100
+)
+
+const (
+	BundleHeader   = "header"
+	BundleConfig   = "config"
+	BundleTask     = "task"
+	BundleArchived = "archived"
+
+	// Что делать с задачей, идентификатор которой уже есть
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictNewID     = "newid"
+)
+
+// BundleRecord - строка файла выгрузки
+type BundleRecord struct {
+	Kind string `json:"kind"`
+	// Заголовок
+	Format     string     `json:"format,omitempty"`
+	Version    int        `json:"version,omitempty"`
+	ExportedAt *time.Time `json:"exportedAt,omitempty"`
+	MediaRoots []string   `json:"mediaRoots,omitempty"`
+	// Config - файл конфигурации без паролей и ключей
+	Config json.RawMessage `json:"config,omitempty"`
+	// Task - задача очереди, истории или архива
+	Task *models.Task `json:"task,omitempty"`
+}
+
+// ExportOptions - что включить в выгрузку
+type ExportOptions struct {
+	Config  bool
+	Archive bool
+}
+
+// PathMapping - замена префикса пути при импорте, например старой медиатеки на новую
+type PathMapping struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ImportOptions - параметры импорта
+type ImportOptions struct {
+	Remap []PathMapping
+	// Conflict - skip (по умолчанию), overwrite или newid
+	Conflict string
+	// Config - заменить файл конфигурации настройками из выгрузки
+	Config bool
+	DryRun bool
+}
+
+// ImportCounts - сколько задач импортировано и как
+type ImportCounts struct {
+	Total       int `json:"total"`
+	Created     int `json:"created"`
+	Overwritten int `json:"overwritten,omitempty"`
+	Renamed     int `json:"renamed,omitempty"`
+	Skipped     int `json:"skipped"`
+}
+
+// ImportSkip - пропущенная задача и причина
+type ImportSkip struct {
+	ID       string `json:"id"`
+	FilePath string `json:"filePath"`
+	Reason   string `json:"reason"`
+}
+
+// ConfigImport - итог импорта настроек
+type ConfigImport struct {
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+	// RestartRequired - настройки записаны и вступят в силу после перезапуска
+	RestartRequired bool `json:"restartRequired,omitempty"`
+}
+
+// ImportSummary - итог импорта, при dryRun - что было бы импортировано
+type ImportSummary struct {
+	DryRun      bool       `json:"dryRun"`
+	Version     int        `json:"version"`
+	ExportedAt  *time.Time `json:"exportedAt,omitempty"`
+	SourceRoots []string   `json:"sourceRoots"`
+
+	Tasks    ImportCounts `json:"tasks"`
+	Archived ImportCounts `json:"archived"`
+	// Remapped - у скольких задач заменены пути
+	Remapped int `json:"remapped"`
+	// MissingFiles - у скольких задач очереди нет исходного файла по новому пути
+	MissingFiles int           `json:"missingFiles"`
+	Config       *ConfigImport `json:"config,omitempty"`
+
+	Skipped  []ImportSkip `json:"skipped,omitempty"`
+	Warnings []string     `json:"warnings,omitempty"`
+}
+
+// BundleService выгружает и загружает очередь, историю, архив и настройки для переноса на другой сервер
+type BundleService struct {
+	cfg       *config.Config
+	db        *database.TaskRepository
+	queue     *QueueService
+	wsService *WebSocketService
+}
+
+func NewBundleService(cfg *config.Config, db *database.TaskRepository, queue *QueueService) *BundleService {
+	return &BundleService{
+		cfg:   cfg,
+		db:    db,
+		queue: queue,
+	}
+}
+
+// SetWebSocketService устанавливает WebSocket сервис для логов
+func (s *BundleService) SetWebSocketService(wsService *WebSocketService) {
+	s.wsService = wsService
+}
+
+// ParsePathMapping читает замену пути вида /old/root=/new/root
+func ParsePathMapping(value string) (PathMapping, error) {
+	from, to, found := strings.Cut(value, "=")
+	if !found || !filepath.IsAbs(from) || !filepath.IsAbs(to) {
+		return PathMapping{}, fmt.Errorf("remap: ожидается /старый/путь=/новый/путь, получено %q", value)
+	}
+	return PathMapping{From: filepath.Clean(from), To: filepath.Clean(to)}, nil
+}
+
+// Export пишет выгрузку в w. Задачи идут в порядке создания
+func (s *BundleService) Export(w io.Writer, opts ExportOptions) error {
+	encoder := json.NewEncoder(w)
+
+	now := time.Now()
+	header := BundleRecord{
+		Kind:       BundleHeader,
+		Format:     bundleFormat,
+		Version:    bundleVersion,
+		ExportedAt: &now,
+		MediaRoots: s.cfg.MediaRoots,
+	}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+
+	if opts.Config {
+		data, err := os.ReadFile(config.Path())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			data, err = config.StripSecrets(data)
+			if err != nil {
+				return fmt.Errorf("файл конфигурации %s содержит некорректный JSON: %v", config.Path(), err)
+			}
+			if err := encoder.Encode(BundleRecord{Kind: BundleConfig, Config: data}); err != nil {
+				return err
+			}
+		}
+	}
+
+	tasks, err := s.db.GetAllTasks()
+	if err != nil {
+		return err
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].ID < tasks[j].ID
+	})
+	for _, task := range tasks {
+		if err := encoder.Encode(BundleRecord{Kind: BundleTask, Task: task}); err != nil {
+			return err
+		}
+	}
+
+	if !opts.Archive {
+		return nil
+	}
+	return s.db.Archive().Each(func(task *models.Task) error {
+		return encoder.Encode(BundleRecord{Kind: BundleArchived, Task: task})
+	})
+}
+
+// Import читает выгрузку из r и добавляет задачи, задачи архива и, с opts.Config, настройки.
+// С opts.DryRun ничего не записывается, итог показывает, что было бы сделано
+func (s *BundleService) Import(r io.Reader, opts ImportOptions) (*ImportSummary, error) {
+	switch opts.Conflict {
+	case "":
+		opts.Conflict = ConflictSkip
+	case ConflictSkip, ConflictOverwrite, ConflictNewID:
+	default:
+		return nil, fmt.Errorf("conflict: неизвестное значение %q", opts.Conflict)
+	}
+
+	bundle, err := readBundle(r)
+	if err != nil {
+		return nil, err
+	}
+	mapper := newPathMapper(opts.Remap)
+
+	summary := &ImportSummary{
+		DryRun:      opts.DryRun,
+		Version:     bundle.header.Version,
+		ExportedAt:  bundle.header.ExportedAt,
+		SourceRoots: bundle.header.MediaRoots,
+		Warnings:    bundle.warnings,
+	}
+	for _, root := range bundle.header.MediaRoots {
+		mapped := mapper.apply(root)
+		if _, err := os.Stat(mapped); err != nil {
+			summary.Warnings = append(summary.Warnings, fmt.Sprintf("Медиатека %s не найдена на этом сервере, задайте remap", mapped))
+		}
+	}
+
+	// Пока идет импорт, новые задачи не добавляются, иначе проверка дубликатов неполная
+	s.queue.enqueueMu.Lock()
+	defer s.queue.enqueueMu.Unlock()
+
+	tasks := s.planTasks(bundle.tasks, mapper, opts.Conflict, summary)
+	archived, err := s.planArchived(bundle.archived, mapper, summary)
+	if err != nil {
+		return nil, err
+	}
+	configData := s.planConfig(bundle.config, mapper, opts.Config, summary)
+
+	if opts.DryRun {
+		return summary, nil
+	}
+
+	if len(tasks) > 0 {
+		if err := s.db.PutTasks(tasks); err != nil {
+			return nil, fmt.Errorf("ошибка сохранения задач: %v", err)
+		}
+	}
+	if err := s.db.Archive().Append(archived); err != nil {
+		return nil, fmt.Errorf("ошибка записи архива: %v", err)
+	}
+	if configData != nil {
+		if err := config.Replace(configData); err != nil {
+			summary.Config.Error = err.Error()
+		} else {
+			summary.Config.Applied = true
+			summary.Config.RestartRequired = true
+		}
+	}
+
+	message := fmt.Sprintf("Импорт: задач %d, из архива %d", summary.Tasks.Created+summary.Tasks.Overwritten+summary.Tasks.Renamed, summary.Archived.Created)
+	if summary.Config != nil && summary.Config.Applied {
+		message += ", настройки заменены (нужен перезапуск)"
+	}
+	log.Println(message)
+	if s.wsService != nil {
+		s.wsService.BroadcastLog(message, "info")
+	}
+	s.queue.broadcastQueueUpdate()
+
+	return summary, nil
+}
+
+// planTasks решает судьбу каждой задачи выгрузки и возвращает задачи для записи
+func (s *BundleService) planTasks(tasks []*models.Task, mapper *pathMapper, conflict string, summary *ImportSummary) []*models.Task {
+	var result []*models.Task
+	seen := make(map[string]bool)
+	activePaths := make(map[string]bool)
+
+	skip := func(task *models.Task, reason string) {
+		summary.Tasks.Skipped++
+		if len(summary.Skipped) < maxImportSkips {
+			summary.Skipped = append(summary.Skipped, ImportSkip{ID: task.ID, FilePath: task.FilePath, Reason: reason})
+		}
+	}
+
+	for _, task := range tasks {
+		summary.Tasks.Total++
+		if task.ID == "" || task.FilePath == "" {
+			skip(task, "нет идентификатора или пути файла")
+			continue
+		}
+		if mapper.remapTask(task) {
+			summary.Remapped++
+		}
+
+		// Процесс FFmpeg остался на старом сервере, задача начнется заново
+		if task.IsActive() {
+			resetToPending(task)
+			task.TempPath = ""
+			task.HoldReason = ""
+			if _, err := os.Stat(task.FilePath); err != nil {
+				summary.MissingFiles++
+			}
+		}
+
+		existing, _ := s.db.GetTask(task.ID)
+		overwrite, renamed := false, false
+		if existing != nil || seen[task.ID] {
+			switch {
+			case conflict == ConflictSkip:
+				skip(task, "задача с таким идентификатором уже есть")
+				continue
+			case conflict == ConflictNewID:
+				task.ID = models.NewTaskID()
+				task.LegacyID = ""
+				renamed = true
+			case seen[task.ID]:
+				skip(task, "идентификатор повторяется в выгрузке")
+				continue
+			case existing.IsActive():
+				skip(task, "незавершенная задача с таким идентификатором не заменяется")
+				continue
+			default:
+				overwrite = true
+			}
+		}
+
+		if task.IsActive() {
+			canonical := canonicalPath(task.FilePath)
+			if active, _ := s.queue.FindActiveTask(task.FilePath); (active != nil && active.ID != task.ID) || activePaths[canonical] {
+				skip(task, "файл уже в очереди")
+				continue
+			}
+			activePaths[canonical] = true
+		}
+
+		switch {
+		case overwrite:
+			summary.Tasks.Overwritten++
+		case renamed:
+			summary.Tasks.Renamed++
+		default:
+			summary.Tasks.Created++
+		}
+		seen[task.ID] = true
+		result = append(result, task)
+	}
+	return result
+}
+
+// planArchived отбирает задачи архива, которых еще нет в архиве этого сервера
+func (s *BundleService) planArchived(tasks []*models.Task, mapper *pathMapper, summary *ImportSummary) ([]*models.Task, error) {
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	existing := make(map[string]bool)
+	err := s.db.Archive().Each(func(task *models.Task) error {
+		existing[task.ID] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения архива: %v", err)
+	}
+
+	var result []*models.Task
+	for _, task := range tasks {
+		summary.Archived.Total++
+		if task.ID == "" || existing[task.ID] {
+			summary.Archived.Skipped++
+			continue
+		}
+		mapper.remapTask(task)
+		existing[task.ID] = true
+		summary.Archived.Created++
+		result = append(result, task)
+	}
+	return result, nil
+}
+
+// configPaths - пути этого сервера в файле конфигурации, "*" - любой элемент массива.
+// В arr.pathMap локальный путь - to, в pathMap уведомлений - from
+var configPaths = [][]string{
+	{"mediaRoots", "*"},
+	{"quarantine", "dir"},
+	{"output", "scratchDir"},
+	{"search", "excludeDirs", "*"},
+	{"arr", "pathMap", "*", "to"},
+	{"notifiers", "*", "pathMap", "*", "from"},
+}
+
+// planConfig заменяет в настройках выгрузки пути этого сервера и проверяет их.
+// Возвращает настройки для записи, если их нужно применить
+func (s *BundleService) planConfig(data json.RawMessage, mapper *pathMapper, apply bool, summary *ImportSummary) []byte {
+	if data == nil {
+		return nil
+	}
+	summary.Config = &ConfigImport{}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		summary.Config.Error = fmt.Sprintf("ошибка парсинга: %v", err)
+		return nil
+	}
+	for _, path := range configPaths {
+		remapConfigPath(fields, path, "", func(name, value string) string {
+			mapped := mapper.apply(value)
+			// Медиатеки уже проверены по заголовку, относительные исключения поиска - не пути
+			if path[0] != "mediaRoots" && filepath.IsAbs(mapped) {
+				if _, err := os.Stat(mapped); err != nil {
+					summary.Warnings = append(summary.Warnings, fmt.Sprintf("Настройки: %s = %s не найден на этом сервере, задайте remap", name, mapped))
+				}
+			}
+			return mapped
+		})
+	}
+
+	result, err := json.MarshalIndent(fields, "", "  ")
+	// Пароли и ключи в выгрузку не попадают, их берем из настроек этого сервера
+	if current, readErr := os.ReadFile(config.Path()); err == nil && readErr == nil {
+		result, err = config.RestoreSecrets(result, current)
+	}
+	if err == nil {
+		_, err = config.Parse(result)
+	}
+	if err != nil {
+		summary.Config.Error = err.Error()
+		return nil
+	}
+	if !apply {
+		return nil
+	}
+	return result
+}
+
+// remapConfigPath заменяет строки по пути path в дереве JSON. name - имя текущего узла для предупреждений
+func remapConfigPath(node interface{}, path []string, name string, fn func(name, value string) string) {
+	if len(path) == 0 {
+		return
+	}
+	switch value := node.(type) {
+	case map[string]interface{}:
+		key := path[0]
+		child, exists := value[key]
+		if !exists || key == "*" {
+			return
+		}
+		if name != "" {
+			key = name + "." + key
+		}
+		if str, ok := child.(string); ok && len(path) == 1 && str != "" {
+			value[path[0]] = fn(key, str)
+			return
+		}
+		remapConfigPath(child, path[1:], key, fn)
+	case []interface{}:
+		if path[0] != "*" {
+			return
+		}
+		for i, item := range value {
+			itemName := fmt.Sprintf("%s[%d]", name, i)
+			if str, ok := item.(string); ok && len(path) == 1 && str != "" {
+				value[i] = fn(itemName, str)
+				continue
+			}
+			remapConfigPath(item, path[1:], itemName, fn)
+		}
+	}
+}
+
+// parsedBundle - прочитанная выгрузка
+type parsedBundle struct {
+	header   BundleRecord
+	config   json.RawMessage
+	tasks    []*models.Task
+	archived []*models.Task
+	warnings []string
+}
+
+// readBundle читает выгрузку целиком и проверяет заголовок
+func readBundle(r io.Reader) (*parsedBundle, error) {
+	decoder := json.NewDecoder(r)
+	bundle := &parsedBundle{}
+
+	if err := decoder.Decode(&bundle.header); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать заголовок выгрузки: %v", err)
+	}
+	if bundle.header.Kind != BundleHeader || bundle.header.Format != bundleFormat {
+		return nil, fmt.Errorf("это не файл выгрузки %s", bundleFormat)
+	}
+	if bundle.header.Version < 1 || bundle.header.Version > bundleVersion {
+		return nil, fmt.Errorf("версия выгрузки %d не поддерживается (поддерживается до %d)", bundle.header.Version, bundleVersion)
+	}
+
+	unknown := make(map[string]bool)
+	for line := 2; ; line++ {
+		var record BundleRecord
+		if err := decoder.Decode(&record); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("запись %d: %v", line, err)
+		}
+
+		switch record.Kind {
+		case BundleConfig:
+			bundle.config = record.Config
+		case BundleTask, BundleArchived:
+			if record.Task == nil {
+				return nil, fmt.Errorf("запись %d: нет задачи", line)
+			}
+			if record.Kind == BundleTask {
+				bundle.tasks = append(bundle.tasks, record.Task)
+			} else {
+				bundle.archived = append(bundle.archived, record.Task)
+			}
+		default:
+			if !unknown[record.Kind] {
+				unknown[record.Kind] = true
+				bundle.warnings = append(bundle.warnings, fmt.Sprintf("Записи вида %q пропущены", record.Kind))
+			}
+		}
+	}
+	return bundle, nil
+}
+
+// pathMapper заменяет префиксы путей, более длинный префикс главнее
+type pathMapper struct {
+	mappings []PathMapping
+}
+
+func newPathMapper(mappings []PathMapping) *pathMapper {
+	sorted := append([]PathMapping(nil), mappings...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i].From) > len(sorted[j].From)
+	})
+	return &pathMapper{mappings: sorted}
+}
+
+// apply возвращает путь с замененным префиксом или исходный путь
+func (m *pathMapper) apply(path string) string {
+	if path == "" {
+		return path
+	}
+	for _, mapping := range m.mappings {
+		if !pathWithin(path, mapping.From) {
+			continue
+		}
+		rel, _ := filepath.Rel(mapping.From, path)
+		return filepath.Join(mapping.To, rel)
+	}
+	return path
+}
+
+// remapTask заменяет пути файлов задачи и возвращает true, если что-то изменилось
+func (m *pathMapper) remapTask(task *models.Task) bool {
+	changed := false
+	for _, path := range []*string{&task.FilePath, &task.OutputPath, &task.TempPath, &task.BackupPath} {
+		if mapped := m.apply(*path); mapped != *path {
+			*path = mapped
+			changed = true
+		}
+	}
+	for i := range task.Sidecars {
+		for _, path := range []*string{&task.Sidecars[i].Source, &task.Sidecars[i].Target} {
+			if mapped := m.apply(*path); mapped != *path {
+				*path = mapped
+				changed = true
+			}
+		}
+	}
+	return changed
+}
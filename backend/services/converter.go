@@ -4,16 +4,17 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
+	"ultimate-dts-fix-server/backend/config"
 	"ultimate-dts-fix-server/backend/models"
 )
 
@@ -21,17 +22,40 @@ type ConverterService struct {
 	queueService   *QueueService
 	stopChan       chan bool
 	wsService      *WebSocketService
+	notifier       *NotifierService
+	webhooks       *WebhookService
+	email          *EmailService
+	probeCache     *ProbeCache
+	cfg            *config.Config
+	scheduler      *Scheduler
+	throttle       config.ThrottleConfig
+	disk           config.DiskConfig
+	output         config.OutputConfig
+	loadMonitor    *loadMonitor
 	activeTask     *models.Task
 	activeCmd      *exec.Cmd
 	activeCtx      context.Context
 	activeCancelFn context.CancelFunc
 	mu             sync.RWMutex
+
+	// Состояние окна расписания и нагрузки, используется только из цикла Start
+	windowOpen        bool
+	scheduleSuspended bool
+	overloadReason    string
 }
 
-func NewConverterService(queueService *QueueService) *ConverterService {
+func NewConverterService(queueService *QueueService, cfg *config.Config) *ConverterService {
+	scheduler := NewScheduler(cfg.Schedule)
 	return &ConverterService{
 		queueService: queueService,
 		stopChan:     make(chan bool),
+		cfg:          cfg,
+		scheduler:    scheduler,
+		throttle:     cfg.Throttle,
+		disk:         cfg.Disk,
+		output:       cfg.Output,
+		loadMonitor:  newLoadMonitor(cfg.Throttle),
+		windowOpen:   scheduler.IsOpen(time.Now()),
 	}
 }
 
@@ -39,16 +63,49 @@ func (s *ConverterService) SetWebSocketService(wsService *WebSocketService) {
 	s.wsService = wsService
 }
 
+func (s *ConverterService) SetNotifierService(notifier *NotifierService) {
+	s.notifier = notifier
+}
+
+func (s *ConverterService) SetWebhookService(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+func (s *ConverterService) SetEmailService(email *EmailService) {
+	s.email = email
+}
+
+func (s *ConverterService) SetProbeCache(probeCache *ProbeCache) {
+	s.probeCache = probeCache
+}
+
+// emitEvent сообщает о событии задачи во внешние webhooks, об ошибках - еще и письмом
+func (s *ConverterService) emitEvent(event string, task *models.Task) {
+	if s.webhooks != nil {
+		s.webhooks.Emit(event, task)
+	}
+	if event == config.EventTaskFailed && s.email != nil {
+		s.email.NotifyFailure(task)
+	}
+}
+
 func (s *ConverterService) Start() {
 	log.Println("Сервис конвертации запущен")
 
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
+	purgeTicker := time.NewTicker(time.Hour)
+	defer purgeTicker.Stop()
+	s.purgeQuarantine()
+
 	for {
 		select {
 		case <-ticker.C:
+			s.enforceSchedule()
 			s.checkForConversion()
+		case <-purgeTicker.C:
+			s.purgeQuarantine()
 		case <-s.stopChan:
 			log.Println("Сервис конвертации остановлен")
 			return
@@ -61,6 +118,11 @@ func (s *ConverterService) Stop() {
 }
 
 func (s *ConverterService) checkForConversion() {
+	// Не берем новые задачи пока очередь на паузе или вне окна расписания
+	if s.queueService.IsPaused() || !s.scheduler.IsOpen(time.Now()) {
+		return
+	}
+
 	// Получаем задачи для конвертации
 	tasks, err := s.queueService.db.GetPendingTasks()
 	if err != nil {
@@ -68,15 +130,150 @@ func (s *ConverterService) checkForConversion() {
 		return
 	}
 
-	// Обрабатываем только первую задачу (одна задача за раз)
-	if len(tasks) > 0 {
-		task := tasks[0]
-		if task.Status == models.StatusPending {
-			go s.convertTask(task)
+	// Одна задача за раз: пока есть выполняющаяся или приостановленная, новые не берем
+	if s.GetActiveTask() != nil {
+		return
+	}
+	for _, task := range tasks {
+		if task.Status != models.StatusPending {
+			return
+		}
+	}
+
+	if len(tasks) == 0 || s.isOverloaded() {
+		return
+	}
+
+	// Запускаем первую задачу, для которой хватает места на диске
+	for _, task := range tasks {
+		if !s.preflight(task) {
+			continue
+		}
+
+		s.mu.Lock()
+		s.activeTask = task
+		s.mu.Unlock()
+
+		go s.convertTask(task)
+		return
+	}
+}
+
+// preflight проверяет свободное место перед запуском задачи. Задача, для
+// которой места нет, остается в очереди с причиной ожидания или завершается ошибкой
+func (s *ConverterService) preflight(task *models.Task) bool {
+	if task.EstimatedSize == 0 {
+		info, err := os.Stat(task.FilePath)
+		if err != nil {
+			// Ошибку доступа к файлу сообщит сама конвертация
+			return true
+		}
+		task.SourceSize = info.Size()
+		task.EstimatedSize = estimateOutputSize(info.Size(), task.AudioInfo, task.Duration)
+	}
+
+	err := checkDiskSpace(s.disk, task.FilePath, task.EstimatedSize)
+	if err == nil && s.output.ScratchDir != "" {
+		// Временный файл пишется на отдельный диск, место нужно на обоих
+		err = checkDiskSpace(s.disk, filepath.Join(s.output.ScratchDir, task.ID), task.EstimatedSize)
+	}
+	if err == nil {
+		task.HoldReason = ""
+		return true
+	}
+
+	reason := err.Error()
+	if s.disk.OnInsufficient == config.OnInsufficientFail {
+		log.Printf("Задача %s не запущена: %s", task.ID, reason)
+		now := time.Now()
+		task.Status = models.StatusError
+		task.Error = reason
+		task.ErrorType = errorTypeOf(err)
+		task.HoldReason = ""
+		task.FinishedAt = &now
+		if err := s.queueService.UpdateTask(task); err != nil {
+			log.Printf("Ошибка обновления задачи: %v", err)
+		}
+		if s.wsService != nil {
+			s.wsService.BroadcastConversionProgress(task.ID, 0, models.StatusError, reason)
+		}
+		s.emitEvent(config.EventTaskFailed, task)
+		return false
+	}
+
+	// Сообщаем об ожидании только один раз, чтобы не сохранять задачу каждый тик
+	if task.HoldReason == "" {
+		log.Printf("Задача %s ожидает свободного места: %s", task.ID, reason)
+		task.HoldReason = reason
+		if err := s.queueService.UpdateTask(task); err != nil {
+			log.Printf("Ошибка обновления задачи: %v", err)
+		}
+		if s.wsService != nil {
+			s.wsService.BroadcastLog("Задача ожидает свободного места: "+reason, "warning")
+		}
+	}
+	return false
+}
+
+// isOverloaded проверяет загрузку системы и сообщает о начале и конце ожидания
+func (s *ConverterService) isOverloaded() bool {
+	reason := s.loadMonitor.overloaded()
+	if reason != "" && s.overloadReason == "" {
+		log.Printf("Запуск задачи отложен: %s", reason)
+		if s.wsService != nil {
+			s.wsService.BroadcastLog("Запуск задачи отложен: "+reason, "warning")
+		}
+	} else if reason == "" && s.overloadReason != "" {
+		log.Println("Нагрузка снизилась, запуск задач возобновлен")
+	}
+	s.overloadReason = reason
+
+	return reason != ""
+}
+
+// enforceSchedule отслеживает открытие и закрытие окна расписания и при
+// политике suspend приостанавливает или возобновляет текущую задачу
+func (s *ConverterService) enforceSchedule() {
+	now := time.Now()
+	open := s.scheduler.IsOpen(now)
+	if open == s.windowOpen {
+		return
+	}
+	s.windowOpen = open
+
+	if open {
+		log.Println("Окно обработки открыто")
+		if s.scheduleSuspended {
+			s.scheduleSuspended = false
+			if task := s.GetActiveTask(); task != nil {
+				if err := s.ResumeConversion(task.ID); err != nil {
+					log.Printf("Не удалось возобновить задачу по расписанию: %v", err)
+				}
+			}
 		}
+	} else {
+		log.Println("Окно обработки закрыто")
+		if s.scheduler.OnWindowClose() == config.OnWindowCloseSuspend {
+			if task := s.GetActiveTask(); task != nil && task.Status == models.StatusProcessing {
+				if err := s.PauseConversion(task.ID); err != nil {
+					log.Printf("Не удалось приостановить задачу по расписанию: %v", err)
+				} else {
+					s.scheduleSuspended = true
+				}
+			}
+		}
+	}
+
+	if s.wsService != nil {
+		s.wsService.BroadcastScheduleState(s.scheduler.State(now))
 	}
 }
 
+// GetScheduleState возвращает текущее состояние расписания
+func (s *ConverterService) GetScheduleState() ScheduleState {
+	return s.scheduler.State(time.Now())
+}
+
 func (s *ConverterService) CancelConversion(taskID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -94,6 +291,97 @@ func (s *ConverterService) CancelConversion(taskID string) error {
 	return fmt.Errorf("невозможно отменить задачу")
 }
 
+// PauseConversion приостанавливает процесс FFmpeg активной задачи
+func (s *ConverterService) PauseConversion(taskID string) error {
+	s.mu.Lock()
+	if s.activeTask == nil || s.activeTask.ID != taskID {
+		s.mu.Unlock()
+		return fmt.Errorf("задача не активна")
+	}
+	if s.activeTask.Status == models.StatusPaused {
+		s.mu.Unlock()
+		return fmt.Errorf("задача уже приостановлена")
+	}
+	if s.activeCmd == nil || s.activeCmd.Process == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("процесс FFmpeg еще не запущен")
+	}
+
+	if err := suspendProcess(s.activeCmd.Process); err != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("ошибка приостановки FFmpeg: %v", err)
+	}
+
+	task := s.activeTask
+	now := time.Now()
+	task.Status = models.StatusPaused
+	task.PausedAt = &now
+	task.Elapsed = task.ElapsedAt(now)
+	s.mu.Unlock()
+	log.Printf("Конвертация приостановлена: %s", taskID)
+
+	// Запись и рассылка идут без блокировки, чтобы не задерживать цикл конвертации
+	s.saveTaskState(task, "Конвертация приостановлена")
+	return nil
+}
+
+// ResumeConversion продолжает приостановленный процесс FFmpeg
+func (s *ConverterService) ResumeConversion(taskID string) error {
+	s.mu.Lock()
+	if s.activeTask == nil || s.activeTask.ID != taskID {
+		s.mu.Unlock()
+		return fmt.Errorf("задача не активна")
+	}
+	if s.activeTask.Status != models.StatusPaused {
+		s.mu.Unlock()
+		return fmt.Errorf("задача не приостановлена")
+	}
+	if s.activeCmd == nil || s.activeCmd.Process == nil {
+		s.mu.Unlock()
+		return fmt.Errorf("процесс FFmpeg еще не запущен")
+	}
+
+	if err := resumeProcess(s.activeCmd.Process); err != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("ошибка возобновления FFmpeg: %v", err)
+	}
+
+	task := s.activeTask
+	endPause(task, time.Now())
+	task.Status = models.StatusProcessing
+	s.mu.Unlock()
+	log.Printf("Конвертация возобновлена: %s", taskID)
+
+	s.saveTaskState(task, "Конвертация возобновлена")
+	return nil
+}
+
+// saveTaskState сохраняет смену статуса активной задачи и уведомляет клиентов
+func (s *ConverterService) saveTaskState(task *models.Task, message string) {
+	if err := s.queueService.UpdateTask(task); err != nil {
+		log.Printf("Ошибка обновления статуса задачи: %v", err)
+	}
+
+	if s.wsService != nil {
+		s.wsService.BroadcastConversionProgress(task.ID, task.Progress, task.Status, message)
+	}
+
+	if task.Status == models.StatusPaused {
+		s.emitEvent(config.EventTaskPaused, task)
+	} else {
+		s.emitEvent(config.EventTaskResumed, task)
+	}
+}
+
+// endPause закрывает текущую паузу задачи и добавляет ее к суммарному времени пауз
+func endPause(task *models.Task, now time.Time) {
+	if task.PausedAt == nil {
+		return
+	}
+	task.PausedSeconds += now.Sub(*task.PausedAt).Seconds()
+	task.PausedAt = nil
+}
+
 func (s *ConverterService) GetActiveTask() *models.Task {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -151,70 +439,99 @@ func (s *ConverterService) convertTask(task *models.Task) {
 	if s.wsService != nil {
 		s.wsService.BroadcastConversionProgress(task.ID, 0, models.StatusProcessing, "Начало конвертации")
 	}
+	s.emitEvent(config.EventTaskStarted, task)
 
-	// Генерируем путь для выходного файла
-	outputPath := s.generateOutputPath(task.FilePath)
-	task.OutputPath = outputPath
+	// Генерируем путь для выходного файла по профилю, FFmpeg пишет во временный
+	err := s.prepareOutputPath(task)
+	if err == nil {
+		task.TempPath = tempOutputPath(s.output.ScratchDir, task.OutputPath, task.ID)
+
+		// Выполняем конвертацию
+		err = s.executeFFmpegConversion(ctx, task)
+	}
+
+	// Задача могла быть отменена во время паузы
+	s.mu.Lock()
+	endPause(task, time.Now())
+	s.mu.Unlock()
+
+	if err == nil {
+		log.Printf("FFmpeg завершил работу успешно для: %s", task.FilePath)
+		err = s.finalizeOutput(task)
+	}
 
-	// Выполняем конвертацию
-	err := s.executeFFmpegConversion(ctx, task)
+	// Временный файл не нужен ни после переноса, ни после ошибки
+	removeTempOutput(task)
+
+	finishedAt := time.Now()
+	task.FinishedAt = &finishedAt
 
 	if err != nil {
-		if ctx.Err() == context.Canceled {
+		task.Elapsed = task.ElapsedAt(finishedAt)
+
+		if isSkipped(err) {
+			task.Status = models.StatusSkipped
+			task.Error = err.Error()
+			log.Printf("Задача пропущена: %v", err)
+
+			if s.wsService != nil {
+				s.wsService.BroadcastConversionProgress(task.ID, 0, models.StatusSkipped, err.Error())
+			}
+			s.emitEvent(config.EventTaskSkipped, task)
+		} else if ctx.Err() == context.Canceled {
 			task.Status = models.StatusError
 			task.Error = "Конвертация отменена пользователем"
+			task.ErrorType = models.ErrorTypeCancelled
 			log.Printf("Конвертация отменена: %s", task.FilePath)
 
 			if s.wsService != nil {
 				s.wsService.BroadcastConversionProgress(task.ID, 0, models.StatusError,
 					"Конвертация отменена")
 			}
+			s.emitEvent(config.EventTaskCancelled, task)
 		} else {
 			task.Status = models.StatusError
 			task.Error = err.Error()
+			task.ErrorType = errorTypeOf(err)
 			log.Printf("Ошибка конвертации: %v", err)
 
 			if s.wsService != nil {
 				s.wsService.BroadcastConversionProgress(task.ID, 0, models.StatusError,
 					"Ошибка конвертации: "+err.Error())
 			}
+			s.emitEvent(config.EventTaskFailed, task)
 		}
 	} else {
-		log.Printf("FFmpeg завершил работу успешно для: %s", task.FilePath)
-
 		task.Status = models.StatusCompleted
-		now = time.Now()
-		task.CompletedAt = &now
+		task.CompletedAt = &finishedAt
+		task.Elapsed = task.ElapsedAt(finishedAt)
 		task.Progress = 100
-		log.Printf("Конвертация завершена: %s -> %s", task.FilePath, outputPath)
-
-		// Проверяем существование выходного файла
-		if _, err := os.Stat(outputPath); err != nil {
-			log.Printf("ОШИБКА: Выходной файл не найден: %s, ошибка: %v", outputPath, err)
-		} else {
-			log.Printf("Выходной файл создан успешно: %s", outputPath)
-		}
+		log.Printf("Конвертация завершена: %s -> %s (%.0f сек без учета пауз)",
+			task.FilePath, task.OutputPath, task.Elapsed)
 
-		// Переименовываем исходный файл в .bak
-		log.Printf("Попытка переименовать исходный файл: %s", task.FilePath)
-		if err := s.renameInputToBak(task.FilePath); err != nil {
-			log.Printf("ОШИБКА: не удалось переименовать исходный файл в .bak: %v", err)
-			log.Printf("Путь к файлу: %s", task.FilePath)
+		// Убираем исходный файл по политике профиля
+		if err := s.finalizeSource(task); err != nil {
+			log.Printf("ОШИБКА: %v", err)
 			// Проверяем существование исходного файла
 			if _, statErr := os.Stat(task.FilePath); statErr != nil {
 				log.Printf("ОШИБКА: Исходный файл не найден: %v", statErr)
 			} else {
-				log.Printf("Исходный файл существует, но не удалось переименовать")
+				log.Printf("Исходный файл существует, но не удалось его перенести")
 			}
-		} else {
-			log.Printf("Исходный файл успешно переименован в .bak: %s", task.FilePath)
 		}
 
+		// Субтитры, nfo и постеры с именем исходника переносим под имя результата
+		s.moveSidecars(task)
+
+		// Исходный файл перенесен или заменен, результат появился - кэш ffprobe устарел
+		s.probeCache.Invalidate(task.FilePath, task.OutputPath, task.BackupPath)
+
 		if s.wsService != nil {
 			s.wsService.BroadcastConversionProgress(task.ID, 100, models.StatusCompleted,
 				"Конвертация завершена")
 			log.Printf("Отправлено WebSocket уведомление о завершении")
 		}
+		s.emitEvent(config.EventTaskCompleted, task)
 	}
 
 	// Обновляем задачу в базе через QueueService (это также отправит WebSocket уведомление)
@@ -225,51 +542,97 @@ func (s *ConverterService) convertTask(task *models.Task) {
 		log.Printf("Задача успешно обновлена в базе: %s, статус: %s", task.ID, task.Status)
 	}
 
+	// Просим медиасерверы пересканировать директорию с новым файлом
+	if task.Status == models.StatusCompleted && s.notifier != nil {
+		s.notifier.NotifyTask(task)
+	}
+
 	log.Printf("Завершение обработки задачи: %s", task.ID)
 }
 
-func (s *ConverterService) generateOutputPath(inputPath string) string {
-	dir := filepath.Dir(inputPath)
-	filename := filepath.Base(inputPath)
+// taskError - ошибка задачи с типом для статистики
+type taskError struct {
+	errorType string
+	message   string
+}
+
+func newTaskError(errorType, format string, args ...interface{}) error {
+	return &taskError{errorType: errorType, message: fmt.Sprintf(format, args...)}
+}
 
-	// Используем регулярное выражение для замены DTS.*5.1 на FLAC.7.1
-	// Паттерн: начинается с DTS, между ними могут быть точки, буквы и дефисы, заканчивается на 5.1
-	baseName := strings.TrimSuffix(filename, filepath.Ext(filename))
-	re := regexp.MustCompile(`DTS[.\-A-Za-z]*5\.1`)
-	baseName = re.ReplaceAllString(baseName, "FLAC.7.1")
+func (e *taskError) Error() string {
+	return e.message
+}
 
-	// Добавляем суффикс если файл уже существует
-	outputPath := filepath.Join(dir, baseName+filepath.Ext(filename))
-	counter := 1
+func (e *taskError) ErrorType() string {
+	return e.errorType
+}
 
-	for {
-		if _, err := os.Stat(outputPath); os.IsNotExist(err) {
-			break
+// errorTypeOf возвращает тип ошибки задачи, ошибки без типа относятся к other
+func errorTypeOf(err error) string {
+	var typed interface{ ErrorType() string }
+	if errors.As(err, &typed) {
+		return typed.ErrorType()
+	}
+	return models.ErrorTypeOther
+}
+
+// finalizeOutput проверяет временный файл и переносит его на итоговое имя
+func (s *ConverterService) finalizeOutput(task *models.Task) error {
+	if err := s.verifyOutput(task); err != nil {
+		return fmt.Errorf("проверка результата не пройдена: %w", err)
+	}
+
+	// Пока шла конвертация, итоговое имя могло оказаться занято
+	if _, err := os.Stat(task.OutputPath); err == nil {
+		if err := s.prepareOutputPath(task); err != nil {
+			return err
 		}
-		outputPath = filepath.Join(dir, fmt.Sprintf("%s_%d%s", baseName, counter, filepath.Ext(filename)))
-		counter++
 	}
 
-	return outputPath
+	if err := moveIntoPlace(task.TempPath, task.OutputPath); err != nil {
+		return newTaskError(models.ErrorTypeFinalize, "ошибка переноса результата: %v", err)
+	}
+
+	task.TempPath = ""
+	log.Printf("Выходной файл создан успешно: %s", task.OutputPath)
+	return nil
 }
 
-func (s *ConverterService) renameInputToBak(inputPath string) error {
-	bakPath := inputPath + ".bak"
+// prepareOutputPath выбирает путь выходного файла по шаблону и политике коллизий профиля
+func (s *ConverterService) prepareOutputPath(task *models.Task) error {
+	profile, err := s.cfg.Profile(task.Profile)
+	if err != nil {
+		return err
+	}
 
-	// Проверяем, не существует ли уже .bak файл
-	if _, err := os.Stat(bakPath); err == nil {
-		// Если существует, добавляем счетчик
-		counter := 1
-		for {
-			bakPath = fmt.Sprintf("%s.bak.%d", inputPath, counter)
-			if _, err := os.Stat(bakPath); os.IsNotExist(err) {
-				break
-			}
-			counter++
-		}
+	// Результат займет место исходного файла после проверки
+	if profile.Finalize.Policy == config.FinalizeReplace {
+		task.OutputPath = task.FilePath
+		return nil
+	}
+
+	outputPath, err := resolveOutputPath(task.FilePath, task.AudioInfo, profile)
+	if err != nil {
+		return err
 	}
 
-	return os.Rename(inputPath, bakPath)
+	task.OutputPath = outputPath
+	return nil
+}
+
+// PreviewOutputPath показывает, какое имя получит результат, не запуская конвертацию
+func (s *ConverterService) PreviewOutputPath(filePath, profileName string, audio *models.AudioInfo) (string, error) {
+	profile, err := s.cfg.Profile(profileName)
+	if err != nil {
+		return "", err
+	}
+	return resolveOutputPath(filePath, audio, profile)
+}
+
+// Profile возвращает профиль конвертации по имени, пустое имя - профиль по умолчанию
+func (s *ConverterService) Profile(name string) (*config.ProfileConfig, error) {
+	return s.cfg.Profile(name)
 }
 
 // AudioStreamInfo содержит информацию об аудио потоке
@@ -281,11 +644,6 @@ type AudioStreamInfo struct {
 	BitRate       string `json:"bit_rate"`
 }
 
-// FFProbeOutput структура для парсинга вывода ffprobe
-type FFProbeOutput struct {
-	Streams []AudioStreamInfo `json:"streams"`
-}
-
 // FFProbeFormat структура для получения длительности
 type FFProbeFormat struct {
 	Duration string `json:"duration"`
@@ -296,35 +654,42 @@ type FFProbeFormatOutput struct {
 	Format FFProbeFormat `json:"format"`
 }
 
-// getAudioInfo получает информацию об аудио потоке через ffprobe
+// getAudioInfo возвращает первую аудиодорожку файла из кэша ffprobe,
+// nil без ошибки - в файле нет аудио
 func (s *ConverterService) getAudioInfo(filePath string) (*AudioStreamInfo, error) {
-	cmd := exec.Command("ffprobe",
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_streams",
-		"-select_streams", "a:0",
-		filePath,
-	)
-
-	output, err := cmd.Output()
+	file, err := s.probeCache.Probe(context.Background(), filePath)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка выполнения ffprobe: %v", err)
+		return nil, err
 	}
-
-	var probeOutput FFProbeOutput
-	if err := json.Unmarshal(output, &probeOutput); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга вывода ffprobe: %v", err)
+	if len(file.Audio) == 0 {
+		return nil, nil
 	}
 
-	if len(probeOutput.Streams) == 0 {
-		return nil, fmt.Errorf("аудио поток не найден")
-	}
-
-	return &probeOutput.Streams[0], nil
+	stream := file.Audio[0]
+	return &AudioStreamInfo{
+		CodecName:     stream.CodecName,
+		ChannelLayout: stream.ChannelLayout,
+		Channels:      stream.Channels,
+		SampleRate:    stream.SampleRate,
+		BitRate:       stream.BitRate,
+	}, nil
 }
 
-// getVideoDuration получает длительность видео через ffprobe
+// getVideoDuration возвращает длительность видео из кэша ffprobe
 func (s *ConverterService) getVideoDuration(filePath string) (float64, error) {
+	file, err := s.probeCache.Probe(context.Background(), filePath)
+	if err != nil {
+		return 0, err
+	}
+	if file.Duration <= 0 {
+		return 0, fmt.Errorf("длительность не определена")
+	}
+	return file.Duration, nil
+}
+
+// probeDuration получает длительность через ffprobe без кэша,
+// используется для проверки только что записанного результата
+func probeDuration(filePath string) (float64, error) {
 	cmd := exec.Command("ffprobe",
 		"-v", "quiet",
 		"-print_format", "json",
@@ -352,7 +717,7 @@ func (s *ConverterService) getVideoDuration(filePath string) (float64, error) {
 
 func (s *ConverterService) executeFFmpegConversion(ctx context.Context, task *models.Task) error {
 	// Команда FFmpeg на основе dts-2-flac.txt
-	cmd := exec.CommandContext(ctx, "ffmpeg",
+	args := []string{
 		"-i", task.FilePath,
 		"-c:v", "copy",
 		"-c:s", "copy",
@@ -366,8 +731,13 @@ func (s *ConverterService) executeFFmpegConversion(ctx context.Context, task *mo
 		"-progress", "pipe:1",
 		"-nostats",
 		"-loglevel", "info",
-		task.OutputPath,
-	)
+	}
+	if s.throttle.Threads > 0 {
+		args = append(args, "-threads", fmt.Sprintf("%d", s.throttle.Threads))
+	}
+	args = append(args, task.TempPath)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 
 	// Сохраняем команду для возможной отмены
 	s.mu.Lock()
@@ -377,17 +747,17 @@ func (s *ConverterService) executeFFmpegConversion(ctx context.Context, task *mo
 	// Создаем pipe для чтения прогресса
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return fmt.Errorf("ошибка создания pipe: %v", err)
+		return newTaskError(models.ErrorTypeFFmpeg, "ошибка создания pipe: %v", err)
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("ошибка создания stderr pipe: %v", err)
+		return newTaskError(models.ErrorTypeFFmpeg, "ошибка создания stderr pipe: %v", err)
 	}
 
-	// Запускаем команду
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("ошибка запуска FFmpeg: %v", err)
+	// Запускаем команду с пониженным приоритетом, если он настроен
+	if err := startWithPriority(cmd, s.throttle); err != nil {
+		return newTaskError(models.ErrorTypeFFmpeg, "ошибка запуска FFmpeg: %v", err)
 	}
 
 	// Читаем прогресс в реальном времени с throttling
@@ -401,7 +771,7 @@ func (s *ConverterService) executeFFmpegConversion(ctx context.Context, task *mo
 		if ctx.Err() == context.Canceled {
 			return ctx.Err()
 		}
-		return fmt.Errorf("ошибка конвертации: %v", err)
+		return newTaskError(models.ErrorTypeFFmpeg, "ошибка конвертации: %v", err)
 	}
 
 	return nil
@@ -413,6 +783,7 @@ func (s *ConverterService) readFFmpegProgress(stdout io.ReadCloser, task *models
 	const updateInterval = 2 * time.Second
 
 	progressMap := make(map[string]string)
+	lastProgress := task.Progress
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -429,43 +800,59 @@ func (s *ConverterService) readFFmpegProgress(stdout io.ReadCloser, task *models
 
 		// Отправляем обновление только раз в 2 секунды
 		if time.Since(lastUpdate) >= updateInterval && len(progressMap) > 0 {
-			if s.wsService != nil {
-				// Вычисляем прогресс
-				progress := s.calculateProgress(progressMap, task)
-				currentTime := s.parseCurrentTime(progressMap)
+			// Вычисляем прогресс
+			progress := s.calculateProgress(progressMap, task)
+			currentTime := s.parseCurrentTime(progressMap)
 
-				// Обновляем текущее время в задаче
-				task.CurrentTime = currentTime
+			// Обновляем текущее время в задаче
+			task.CurrentTime = currentTime
+			task.Progress = int(progress)
+			task.Elapsed = task.ElapsedAt(time.Now())
 
-				log.Printf("[FFmpeg Progress] Task %s: %.1f%% (%.1f/%.1f sec)",
-					task.ID, progress, currentTime, task.Duration)
+			log.Printf("[FFmpeg Progress] Task %s: %.1f%% (%.1f/%.1f sec)",
+				task.ID, progress, currentTime, task.Duration)
 
+			if s.wsService != nil {
 				s.wsService.BroadcastConversionProgress(
 					task.ID,
 					int(progress),
-					models.StatusProcessing,
+					task.Status,
 					"",
 				)
 			}
+			// Webhooks получают пороги прогресса и без WebSocket
+			s.emitProgress(task, lastProgress)
+			lastProgress = task.Progress
 			lastUpdate = time.Now()
 		}
 	}
 
 	// Отправляем последнее обновление если есть данные
-	if len(progressMap) > 0 && s.wsService != nil {
+	if len(progressMap) > 0 {
 		progress := s.calculateProgress(progressMap, task)
 		currentTime := s.parseCurrentTime(progressMap)
 		task.CurrentTime = currentTime
+		task.Progress = int(progress)
 
 		log.Printf("[FFmpeg Progress Final] Task %s: %.1f%% (%.1f/%.1f sec)",
 			task.ID, progress, currentTime, task.Duration)
 
-		s.wsService.BroadcastConversionProgress(
-			task.ID,
-			int(progress),
-			models.StatusProcessing,
-			"",
-		)
+		if s.wsService != nil {
+			s.wsService.BroadcastConversionProgress(
+				task.ID,
+				int(progress),
+				task.Status,
+				"",
+			)
+		}
+		s.emitProgress(task, lastProgress)
+	}
+}
+
+// emitProgress отправляет в webhooks пороги прогресса, пройденные с предыдущего обновления
+func (s *ConverterService) emitProgress(task *models.Task, previous int) {
+	if s.webhooks != nil {
+		s.webhooks.EmitProgress(task, previous, task.Progress)
 	}
 }
 
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+const (
+	// flacBitsPerSample - разрядность, с которой обычно декодируется DTS-HD MA
+	flacBitsPerSample = 24
+	// flacOutputChannels - число каналов после апмикса в 7.1
+	flacOutputChannels = 8
+	// flacCompressionRatio - типичное сжатие FLAC для многоканального кино
+	flacCompressionRatio = 0.65
+	// unknownDurationFactor - запас при неизвестной длительности
+	unknownDurationFactor = 1.15
+)
+
+// insufficientSpaceError - на целевом диске не хватает места для выходного файла
+type insufficientSpaceError struct {
+	dir      string
+	required uint64
+	free     uint64
+}
+
+func (e *insufficientSpaceError) Error() string {
+	return fmt.Sprintf("недостаточно места в %s: требуется %s, свободно %s",
+		e.dir, formatBytes(e.required), formatBytes(e.free))
+}
+
+func (e *insufficientSpaceError) ErrorType() string {
+	return models.ErrorTypeDiskSpace
+}
+
+// estimateOutputSize оценивает размер выходного файла: исходный файл
+// без первой аудиодорожки плюс та же дорожка в FLAC 7.1
+func estimateOutputSize(sourceSize int64, audio *models.AudioInfo, duration float64) int64 {
+	if duration <= 0 || audio == nil {
+		return int64(float64(sourceSize) * unknownDurationFactor)
+	}
+
+	sampleRate, err := strconv.ParseFloat(audio.SampleRate, 64)
+	if err != nil || sampleRate <= 0 {
+		sampleRate = 48000
+	}
+	flacBytes := sampleRate * flacBitsPerSample * flacOutputChannels * flacCompressionRatio / 8 * duration
+
+	// Битрейт DTS-HD MA ffprobe часто не сообщает, тогда считаем дорожку нулевой
+	var sourceAudioBytes float64
+	if bitRate, err := strconv.ParseFloat(audio.BitRate, 64); err == nil {
+		sourceAudioBytes = bitRate / 8 * duration
+	}
+
+	estimate := float64(sourceSize) - sourceAudioBytes + flacBytes
+	if estimate < float64(sourceSize) {
+		estimate = float64(sourceSize)
+	}
+	return int64(estimate)
+}
+
+// checkDiskSpace проверяет, что в директории выходного файла хватит места
+// под оценку размера с запасом. Если свободное место узнать нельзя, проверка пропускается
+func checkDiskSpace(cfg config.DiskConfig, outputPath string, estimatedSize int64) error {
+	dir := filepath.Dir(outputPath)
+
+	free, err := freeSpace(dir)
+	if err != nil {
+		return nil
+	}
+
+	required := uint64(float64(estimatedSize)*(1+cfg.SafetyMarginPercent/100)) + uint64(cfg.MinFreeMB)*1024*1024
+	if free < required {
+		return &insufficientSpaceError{dir: dir, required: required, free: free}
+	}
+
+	return nil
+}
+
+// formatBytes форматирует размер в человекочитаемый вид
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
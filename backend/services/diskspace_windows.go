@@ -0,0 +1,10 @@
+//go:build windows
+
+package services
+
+import "fmt"
+
+// freeSpace не поддерживается на Windows, проверка места пропускается
+func freeSpace(dir string) (uint64, error) {
+	return 0, fmt.Errorf("проверка свободного места не поддерживается на этой платформе")
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// DuplicateError - файл уже в очереди или уже был сконвертирован
+type DuplicateError struct {
+	// Task - существующая задача, nil если найден только выходной файл
+	Task *models.Task `json:"task,omitempty"`
+	// Active - для файла есть незавершенная задача, такой дубликат не добавляется даже с force
+	Active bool `json:"active"`
+	// OutputPath - уже существующий результат конвертации
+	OutputPath string `json:"outputPath,omitempty"`
+}
+
+func (e *DuplicateError) Error() string {
+	switch {
+	case e.Active:
+		return fmt.Sprintf("Файл уже в очереди (задача %s)", e.Task.ID)
+	case e.Task != nil:
+		return fmt.Sprintf("Файл уже сконвертирован (задача %s)", e.Task.ID)
+	default:
+		return fmt.Sprintf("Результат конвертации уже существует: %s", e.OutputPath)
+	}
+}
+
+// checkDuplicate ищет незавершенную задачу для того же файла и признаки того,
+// что файл уже сконвертирован. С force проверяются только незавершенные задачи
+func (s *QueueService) checkDuplicate(task *models.Task, force bool) error {
+	canonical := canonicalPath(task.FilePath)
+
+	active, err := s.db.GetPendingTasks()
+	if err != nil {
+		return err
+	}
+	for _, existing := range active {
+		if canonicalPath(existing.FilePath) == canonical {
+			return &DuplicateError{Task: existing, Active: true}
+		}
+	}
+
+	if force {
+		return nil
+	}
+
+	info, err := os.Stat(task.FilePath)
+	if err != nil {
+		return err
+	}
+
+	// Последняя задача, у которой этот путь - исходник или результат, считается той же,
+	// если файл совпадает по размеру с ее исходником (исходник сохранен) или результатом
+	// (результат занял место исходника). Новый файл по тому же пути, например обновление
+	// из Sonarr, конвертируется заново
+	for _, path := range []string{task.FilePath, canonical} {
+		existing := s.db.FindTasksByPath(path)
+		if len(existing) == 0 || existing[0].Status != models.StatusCompleted {
+			continue
+		}
+		if info.Size() == existing[0].SourceSize || info.Size() == existing[0].OutputSize {
+			return &DuplicateError{Task: existing[0]}
+		}
+	}
+
+	if s.converter != nil {
+		if outputPath := s.converter.existingOutput(task); outputPath != "" {
+			return &DuplicateError{OutputPath: outputPath}
+		}
+	}
+
+	return nil
+}
+
+// existingOutput возвращает путь результата, который получила бы задача, если такой
+// файл уже есть рядом с исходником. Для политики replace результат не отличить от исходника
+func (s *ConverterService) existingOutput(task *models.Task) string {
+	profile, err := s.cfg.Profile(task.Profile)
+	if err != nil || profile.Finalize.Policy == config.FinalizeReplace {
+		return ""
+	}
+
+	outputPath := filepath.Join(filepath.Dir(task.FilePath), buildOutputName(task.FilePath, task.AudioInfo, profile))
+	if outputPath == task.FilePath {
+		return ""
+	}
+	if _, err := os.Stat(outputPath); err != nil {
+		return ""
+	}
+	return outputPath
+}
+
+// canonicalPath возвращает путь без символических ссылок, чтобы один файл,
+// добавленный по разным путям, считался дубликатом
+func canonicalPath(path string) string {
+	path = filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/database"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// newTestQueue создает очередь с хранилищем во временной директории и профилем по умолчанию
+func newTestQueue(t *testing.T) (*QueueService, *database.TaskRepository) {
+	t.Helper()
+	db := newTestDB(t, t.TempDir())
+
+	cfg := config.Default()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	queue := NewQueueService(db)
+	queue.SetConverterService(NewConverterService(queue, cfg))
+	return queue, db
+}
+
+func TestCheckDuplicate(t *testing.T) {
+	const sourceName = "Movie.DTS-HD.MA.5.1.mkv"
+	// Имя результата профиля по умолчанию для sourceName
+	const outputName = "Movie.FLAC.7.1.mkv"
+	const sourceSize = 100
+
+	tests := []struct {
+		name string
+		// existing - задача, уже сохраненная в хранилище, пути относительно медиатеки
+		existing *models.Task
+		// files - дополнительные файлы рядом с исходником
+		files []string
+		// viaLink - файл добавляется по символической ссылке
+		viaLink bool
+		force   bool
+		// want - active, converted, output или пусто, если дубликата нет
+		want string
+	}{
+		{name: "new file"},
+		{
+			name:     "pending task",
+			existing: &models.Task{FilePath: sourceName, Status: models.StatusPending},
+			want:     "active",
+		},
+		{
+			name:     "processing task with force",
+			existing: &models.Task{FilePath: sourceName, Status: models.StatusProcessing},
+			force:    true,
+			want:     "active",
+		},
+		{
+			name:     "pending task via symlink",
+			existing: &models.Task{FilePath: sourceName, Status: models.StatusPending},
+			viaLink:  true,
+			want:     "active",
+		},
+		{
+			name:     "converted, source kept",
+			existing: &models.Task{FilePath: sourceName, Status: models.StatusCompleted, SourceSize: sourceSize, OutputSize: 500},
+			want:     "converted",
+		},
+		{
+			name: "converted, result in place of source",
+			existing: &models.Task{FilePath: "Old.mkv", OutputPath: sourceName, Status: models.StatusCompleted,
+				SourceSize: 500, OutputSize: sourceSize},
+			want: "converted",
+		},
+		{
+			name:     "converted with force",
+			existing: &models.Task{FilePath: sourceName, Status: models.StatusCompleted, SourceSize: sourceSize},
+			force:    true,
+		},
+		{
+			name:     "replaced by a new file",
+			existing: &models.Task{FilePath: sourceName, Status: models.StatusCompleted, SourceSize: 500, OutputSize: 400},
+		},
+		{
+			name:     "failed task",
+			existing: &models.Task{FilePath: sourceName, Status: models.StatusError, SourceSize: sourceSize},
+		},
+		{
+			name:  "result already exists",
+			files: []string{outputName},
+			want:  "output",
+		},
+		{
+			name:  "result already exists with force",
+			files: []string{outputName},
+			force: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue, db := newTestQueue(t)
+			media := t.TempDir()
+
+			sourcePath := filepath.Join(media, sourceName)
+			writeTestFile(t, sourcePath, sourceSize)
+			for _, name := range tt.files {
+				writeTestFile(t, filepath.Join(media, name), 10)
+			}
+
+			if tt.existing != nil {
+				existing := *tt.existing
+				existing.ID = models.NewTaskID()
+				existing.FilePath = filepath.Join(media, existing.FilePath)
+				if existing.OutputPath != "" {
+					existing.OutputPath = filepath.Join(media, existing.OutputPath)
+				}
+				if err := db.CreateTask(&existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			filePath := sourcePath
+			if tt.viaLink {
+				filePath = filepath.Join(t.TempDir(), "link.mkv")
+				if err := os.Symlink(sourcePath, filePath); err != nil {
+					t.Skipf("symlinks unavailable: %v", err)
+				}
+			}
+
+			err := queue.checkDuplicate(&models.Task{ID: models.NewTaskID(), FilePath: filePath}, tt.force)
+
+			got := ""
+			var duplicate *DuplicateError
+			switch {
+			case err == nil:
+			case !errors.As(err, &duplicate):
+				t.Fatalf("checkDuplicate() error = %v, want DuplicateError", err)
+			case duplicate.Active:
+				got = "active"
+			case duplicate.Task != nil:
+				got = "converted"
+			case duplicate.OutputPath != "":
+				got = "output"
+				if want := filepath.Join(media, outputName); duplicate.OutputPath != want {
+					t.Errorf("OutputPath = %q, want %q", duplicate.OutputPath, want)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("checkDuplicate() = %q (%v), want %q", got, err, tt.want)
+			}
+		})
+	}
+}
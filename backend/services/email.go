@@ -0,0 +1,306 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/database"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// emailDigestSentKey - время последней отправленной сводки в служебном состоянии
+const emailDigestSentKey = "email_digest_sent_at"
+
+// EmailService отправляет письма об ошибках задач и ежедневную сводку
+type EmailService struct {
+	cfg      config.EmailConfig
+	db       *database.TaskRepository
+	stopChan chan bool
+}
+
+func NewEmailService(cfg config.EmailConfig, db *database.TaskRepository) *EmailService {
+	return &EmailService{
+		cfg:      cfg,
+		db:       db,
+		stopChan: make(chan bool),
+	}
+}
+
+// Start раз в минуту проверяет, не пора ли отправить сводку
+func (s *EmailService) Start() {
+	if !s.cfg.Digest.Enabled {
+		return
+	}
+	log.Printf("Ежедневная сводка будет отправляться в %s", s.cfg.Digest.Time)
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	s.checkDigest(time.Now())
+	for {
+		select {
+		case now := <-ticker.C:
+			s.checkDigest(now)
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *EmailService) Stop() {
+	s.stopChan <- true
+}
+
+// NotifyFailure асинхронно отправляет письмо об ошибке задачи
+func (s *EmailService) NotifyFailure(task *models.Task) {
+	if !s.cfg.OnFailure {
+		return
+	}
+
+	subject := "Ошибка конвертации: " + filepath.Base(task.FilePath)
+	var body strings.Builder
+	fmt.Fprintf(&body, "Задача: %s\n", task.ID)
+	fmt.Fprintf(&body, "Файл: %s\n", task.FilePath)
+	if task.Profile != "" {
+		fmt.Fprintf(&body, "Профиль: %s\n", task.Profile)
+	}
+	if task.StartedAt != nil {
+		fmt.Fprintf(&body, "Запущена: %s\n", task.StartedAt.Format("02.01.2006 15:04:05"))
+	}
+	fmt.Fprintf(&body, "Прогресс: %d%%\n", task.Progress)
+	fmt.Fprintf(&body, "\nОшибка:\n%s\n", task.Error)
+
+	go func() {
+		if err := s.Send(subject, body.String()); err != nil {
+			log.Printf("Письмо об ошибке задачи %s не отправлено: %v", task.ID, err)
+		}
+	}()
+}
+
+// checkDigest отправляет сводку, если наступило время и сегодня она еще не отправлялась
+func (s *EmailService) checkDigest(now time.Time) {
+	minutes, err := s.cfg.Digest.Minutes()
+	if err != nil {
+		return
+	}
+	due := time.Date(now.Year(), now.Month(), now.Day(), 0, minutes, 0, 0, now.Location())
+	if now.Before(due) {
+		return
+	}
+
+	var lastSent time.Time
+	if _, err := s.db.GetState(emailDigestSentKey, &lastSent); err != nil {
+		log.Printf("Ошибка чтения времени последней сводки: %v", err)
+		return
+	}
+	if !lastSent.Before(due) {
+		return
+	}
+	// Первая сводка охватывает последние сутки
+	if lastSent.IsZero() {
+		lastSent = due.AddDate(0, 0, -1)
+	}
+
+	if err := s.SendDigest(lastSent, now); err != nil {
+		log.Printf("Ежедневная сводка не отправлена: %v", err)
+		return
+	}
+
+	if err := s.db.SetState(emailDigestSentKey, now); err != nil {
+		log.Printf("Ошибка сохранения времени сводки: %v", err)
+	}
+}
+
+// SendDigest отправляет сводку по задачам, завершенным в промежутке (since, until].
+// Если задач нет, письмо не отправляется
+func (s *EmailService) SendDigest(since, until time.Time) error {
+	tasks, err := s.db.GetFinishedTasksSince(since)
+	if err != nil {
+		return err
+	}
+
+	var completed, failed, skipped, reverted []*models.Task
+	var sourceBytes, outputBytes int64
+	for _, task := range tasks {
+		if task.ActivityAt().After(until) {
+			continue
+		}
+		switch task.Status {
+		case models.StatusCompleted:
+			completed = append(completed, task)
+			sourceBytes += task.SourceSize
+			outputBytes += task.OutputSize
+		case models.StatusError:
+			failed = append(failed, task)
+		case models.StatusSkipped:
+			skipped = append(skipped, task)
+		case models.StatusReverted:
+			reverted = append(reverted, task)
+		}
+	}
+
+	if len(completed)+len(failed)+len(skipped)+len(reverted) == 0 {
+		log.Println("Сводка не отправлена: за период нет завершенных задач")
+		return nil
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "Сводка с %s по %s\n\n",
+		since.Format("02.01.2006 15:04"), until.Format("02.01.2006 15:04"))
+	fmt.Fprintf(&body, "Завершено: %d\n", len(completed))
+	fmt.Fprintf(&body, "Ошибок: %d\n", len(failed))
+	fmt.Fprintf(&body, "Пропущено: %d\n", len(skipped))
+	fmt.Fprintf(&body, "Откачено: %d\n", len(reverted))
+	if len(completed) > 0 {
+		fmt.Fprintf(&body, "\nИсходные файлы: %s, результаты: %s, изменение: %s\n",
+			formatBytes(uint64(sourceBytes)), formatBytes(uint64(outputBytes)), formatBytesDelta(outputBytes-sourceBytes))
+	}
+
+	if len(completed) > 0 {
+		body.WriteString("\nЗавершенные конвертации:\n")
+		for _, task := range completed {
+			fmt.Fprintf(&body, "- %s -> %s (%s, %s)\n", filepath.Base(task.FilePath), filepath.Base(task.OutputPath),
+				formatBytesDelta(task.OutputSize-task.SourceSize), formatSeconds(task.Elapsed))
+		}
+	}
+	if len(failed) > 0 {
+		body.WriteString("\nОшибки:\n")
+		for _, task := range failed {
+			fmt.Fprintf(&body, "- %s: %s\n", filepath.Base(task.FilePath), task.Error)
+		}
+	}
+	if len(skipped) > 0 {
+		body.WriteString("\nПропущено:\n")
+		for _, task := range skipped {
+			fmt.Fprintf(&body, "- %s: %s\n", filepath.Base(task.FilePath), task.Error)
+		}
+	}
+
+	subject := fmt.Sprintf("Сводка конвертаций за %s: %d завершено, %d ошибок",
+		until.Format("02.01.2006"), len(completed), len(failed))
+	return s.Send(subject, body.String())
+}
+
+// Send отправляет текстовое письмо всем получателям
+func (s *EmailService) Send(subject, body string) error {
+	message, err := s.buildMessage(subject, body)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host}
+
+	var client *smtp.Client
+	if s.cfg.TLS == config.EmailTLSImplicit {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("ошибка подключения к %s: %v", addr, err)
+		}
+		client, err = smtp.NewClient(conn, s.cfg.Host)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	} else {
+		conn, err := net.DialTimeout("tcp", addr, 30*time.Second)
+		if err != nil {
+			return fmt.Errorf("ошибка подключения к %s: %v", addr, err)
+		}
+		client, err = smtp.NewClient(conn, s.cfg.Host)
+		if err != nil {
+			conn.Close()
+			return err
+		}
+	}
+	defer client.Close()
+
+	if s.cfg.TLS == config.EmailTLSStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("ошибка STARTTLS: %v", err)
+		}
+	}
+
+	if s.cfg.Username != "" {
+		auth := smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("ошибка авторизации: %v", err)
+		}
+	}
+
+	// Адреса проверены при загрузке конфигурации
+	from, _ := mail.ParseAddress(s.cfg.From)
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range s.cfg.To {
+		address, _ := mail.ParseAddress(to)
+		if err := client.Rcpt(address.Address); err != nil {
+			return fmt.Errorf("получатель %s отклонен: %v", to, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(message); err != nil {
+		writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// buildMessage формирует письмо в UTF-8 с quoted-printable телом
+func (s *EmailService) buildMessage(subject, body string) ([]byte, error) {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	writer := quotedprintable.NewWriter(&msg)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return msg.Bytes(), nil
+}
+
+// formatBytesDelta форматирует изменение размера со знаком
+func formatBytesDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + formatBytes(uint64(-delta))
+	}
+	return "+" + formatBytes(uint64(delta))
+}
+
+// formatSeconds форматирует длительность в виде 1ч 05м или 3м 20с
+func formatSeconds(seconds float64) string {
+	d := time.Duration(seconds) * time.Second
+	if d >= time.Hour {
+		return fmt.Sprintf("%dч %02dм", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dм %02dс", int(d.Minutes()), int(d.Seconds())%60)
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// enqueueProbeWorkers - сколько файлов пакета add_tasks проверяется одновременно
+const enqueueProbeWorkers = 4
+
+const (
+	EnqueueAdded   = "added"
+	EnqueueSkipped = "skipped"
+)
+
+// EnqueueResult - итог добавления одного файла пакета
+type EnqueueResult struct {
+	FilePath string `json:"filePath"`
+	Status   string `json:"status"`
+	TaskID   string `json:"taskId,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// prepareTask проверяет файл, получает информацию об аудио и создает задачу
+// для постановки в очередь. Используется командой add_task и входящими webhooks
+func (s *ConverterService) prepareTask(filePath, profileName string) (*models.Task, error) {
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Файл не существует")
+	}
+
+	if !isVideoFile(filePath) {
+		return nil, fmt.Errorf("Файл не является видеофайлом")
+	}
+
+	profile, err := s.Profile(profileName)
+	if err != nil {
+		return nil, err
+	}
+
+	audioInfo, err := s.getAudioInfo(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка получения аудио информации")
+	}
+	if audioInfo == nil {
+		return nil, fmt.Errorf("В файле нет аудиодорожки")
+	}
+
+	task := &models.Task{
+		ID:        models.NewTaskID(),
+		FilePath:  filePath,
+		Profile:   profile.Name,
+		Status:    models.StatusPending,
+		Progress:  0,
+		CreatedAt: time.Now(),
+		AudioInfo: &models.AudioInfo{
+			CodecName:     audioInfo.CodecName,
+			ChannelLayout: audioInfo.ChannelLayout,
+			Channels:      audioInfo.Channels,
+			SampleRate:    audioInfo.SampleRate,
+			BitRate:       audioInfo.BitRate,
+		},
+	}
+
+	// Оцениваем размер результата, чтобы показать его в очереди до запуска
+	if duration, err := s.getVideoDuration(filePath); err == nil {
+		task.Duration = duration
+	}
+	if info != nil {
+		task.SourceSize = info.Size()
+		task.EstimatedSize = estimateOutputSize(info.Size(), task.AudioInfo, task.Duration)
+	}
+
+	return task, nil
+}
+
+// enqueueFiles проверяет файлы параллельно и добавляет подходящие в очередь в исходном
+// порядке. Файлы с незавершенной задачей, повторы внутри пакета и, без force,
+// уже сконвертированные файлы пропускаются
+func (s *WebSocketService) enqueueFiles(filePaths []string, profileName string, force bool) []EnqueueResult {
+	results := make([]EnqueueResult, len(filePaths))
+	tasks := make([]*models.Task, len(filePaths))
+
+	seen := make(map[string]bool, len(filePaths))
+	var indexes []int
+	for i, filePath := range filePaths {
+		filePath = filepath.Clean(filePath)
+		results[i] = EnqueueResult{FilePath: filePath, Status: EnqueueSkipped}
+
+		if seen[filePath] {
+			results[i].Reason = "Файл уже есть в этом пакете"
+			continue
+		}
+		seen[filePath] = true
+
+		if active, err := s.queueService.FindActiveTask(filePath); err != nil {
+			results[i].Reason = err.Error()
+			continue
+		} else if active != nil {
+			results[i].TaskID = active.ID
+			results[i].Reason = "Файл уже в очереди"
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < enqueueProbeWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				task, err := s.converterService.prepareTask(results[i].FilePath, profileName)
+				if err != nil {
+					results[i].Reason = err.Error()
+					continue
+				}
+				tasks[i] = task
+			}
+		}()
+	}
+	for _, i := range indexes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, task := range tasks {
+		if task == nil {
+			continue
+		}
+		// Время создания задает порядок в очереди, поэтому идентификатор и время
+		// назначаются заново после параллельной проверки
+		task.ID = models.NewTaskID()
+		task.CreatedAt = time.Now()
+		if err := s.queueService.Enqueue(task, force); err != nil {
+			results[i].Reason = err.Error()
+			var duplicate *DuplicateError
+			if errors.As(err, &duplicate) && duplicate.Task != nil {
+				results[i].TaskID = duplicate.Task.ID
+			}
+			continue
+		}
+
+		results[i].Status = EnqueueAdded
+		results[i].TaskID = task.ID
+	}
+
+	return results
+}
+
+// enqueueDirectory добавляет в очередь видеофайлы директории медиатеки
+func (s *WebSocketService) enqueueDirectory(dir string, recursive bool, profileName string, force bool) ([]EnqueueResult, bool, error) {
+	listing, err := s.listDirectory(dir, recursive)
+	if err != nil {
+		return nil, false, err
+	}
+
+	filePaths := make([]string, 0, len(listing.Files))
+	for _, file := range listing.Files {
+		filePaths = append(filePaths, file.Path)
+	}
+	log.Printf("Добавление директории %s: найдено видеофайлов %d", listing.Path, len(filePaths))
+
+	return s.enqueueFiles(filePaths, profileName, force), listing.Truncated, nil
+}
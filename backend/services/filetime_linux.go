@@ -0,0 +1,17 @@
+//go:build linux
+
+package services
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// changeTime возвращает время последнего изменения метаданных файла (ctime)
+func changeTime(info os.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec)
+	}
+	return info.ModTime()
+}
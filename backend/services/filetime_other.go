@@ -0,0 +1,13 @@
+//go:build !linux
+
+package services
+
+import (
+	"os"
+	"time"
+)
+
+// changeTime возвращает время изменения файла, ctime доступен только в Linux
+func changeTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
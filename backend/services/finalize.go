@@ -0,0 +1,153 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// finalizeSource убирает исходный файл по политике профиля и запоминает, куда он перенесен
+func (s *ConverterService) finalizeSource(task *models.Task) error {
+	profile, err := s.cfg.Profile(task.Profile)
+	if err != nil {
+		return err
+	}
+
+	task.Finalize = profile.Finalize.Policy
+
+	switch profile.Finalize.Policy {
+	case config.FinalizeReplace:
+		// Результат уже перенесен на место исходного файла
+		log.Printf("Исходный файл заменен результатом: %s", task.FilePath)
+		return nil
+
+	case config.FinalizeQuarantine:
+		quarantinePath, err := s.quarantineSource(task.FilePath)
+		if err != nil {
+			return fmt.Errorf("не удалось перенести исходный файл в карантин: %v", err)
+		}
+		task.BackupPath = quarantinePath
+		log.Printf("Исходный файл перенесен в карантин: %s", quarantinePath)
+		return nil
+	}
+
+	bakPath, err := renameInputToBak(task.FilePath)
+	if err != nil {
+		return fmt.Errorf("не удалось переименовать исходный файл в .bak: %v", err)
+	}
+	task.BackupPath = bakPath
+	log.Printf("Исходный файл успешно переименован в .bak: %s", bakPath)
+	return nil
+}
+
+// renameInputToBak переименовывает исходный файл в .bak (или .bak.N) и возвращает новое имя
+func renameInputToBak(inputPath string) (string, error) {
+	bakPath := inputPath + ".bak"
+
+	// Проверяем, не существует ли уже .bak файл
+	if _, err := os.Stat(bakPath); err == nil {
+		// Если существует, добавляем счетчик
+		counter := 1
+		for {
+			bakPath = fmt.Sprintf("%s.bak.%d", inputPath, counter)
+			if _, err := os.Stat(bakPath); os.IsNotExist(err) {
+				break
+			}
+			counter++
+		}
+	}
+
+	return bakPath, os.Rename(inputPath, bakPath)
+}
+
+// quarantinePathFor возвращает путь в карантине, повторяющий структуру медиатеки
+func (s *ConverterService) quarantinePathFor(inputPath string) string {
+	relPath := strings.TrimPrefix(filepath.Clean(inputPath), string(filepath.Separator))
+	for _, root := range s.cfg.MediaRoots {
+		if rel, err := filepath.Rel(root, inputPath); err == nil && !strings.HasPrefix(rel, "..") {
+			relPath = filepath.Join(filepath.Base(root), rel)
+			break
+		}
+	}
+
+	return filepath.Join(s.cfg.Quarantine.Dir, relPath)
+}
+
+// quarantineSource переносит исходный файл в карантин и возвращает его новый путь
+func (s *ConverterService) quarantineSource(inputPath string) (string, error) {
+	target := s.quarantinePathFor(inputPath)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+
+	// В карантине уже может быть файл с таким именем от прошлой конвертации
+	ext := filepath.Ext(target)
+	base := strings.TrimSuffix(target, ext)
+	for counter := 1; ; counter++ {
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			break
+		}
+		target = fmt.Sprintf("%s_%d%s", base, counter, ext)
+	}
+
+	return target, moveIntoPlace(inputPath, target)
+}
+
+// purgeQuarantine удаляет из карантина файлы, пролежавшие дольше PurgeAfterDays.
+// Возраст отсчитывается от переноса в карантин (ctime), а не от изменения файла
+func (s *ConverterService) purgeQuarantine() {
+	dir := s.cfg.Quarantine.Dir
+	days := s.cfg.Quarantine.PurgeAfterDays
+	if dir == "" || days <= 0 {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	var removed int
+	var freed int64
+	var dirs []string
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path != dir {
+				dirs = append(dirs, path)
+			}
+			return nil
+		}
+		if changeTime(info).After(cutoff) {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			log.Printf("Не удалось удалить файл из карантина %s: %v", path, err)
+			return nil
+		}
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	if err != nil {
+		log.Printf("Ошибка очистки карантина: %v", err)
+	}
+
+	// Удаляем опустевшие директории, начиная с самых глубоких
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Remove(dirs[i])
+	}
+
+	if removed > 0 {
+		message := fmt.Sprintf("Очистка карантина: удалено файлов %d, освобождено %s", removed, formatBytes(uint64(freed)))
+		log.Println(message)
+		if s.wsService != nil {
+			s.wsService.BroadcastLog(message, "info")
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/database"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// HistoryService переносит истекшие задачи из истории в архив по настройкам history
+type HistoryService struct {
+	cfg       config.HistoryConfig
+	db        *database.TaskRepository
+	wsService *WebSocketService
+	stopChan  chan bool
+}
+
+// PurgeResult - итог очистки истории
+type PurgeResult struct {
+	// Matched - сколько завершенных задач подошло под условия
+	Matched int `json:"matched"`
+	// Removed - сколько удалено из истории, при dryRun всегда 0
+	Removed  int  `json:"removed"`
+	Archived bool `json:"archived"`
+	DryRun   bool `json:"dryRun,omitempty"`
+}
+
+func NewHistoryService(cfg config.HistoryConfig, db *database.TaskRepository) *HistoryService {
+	return &HistoryService{
+		cfg:      cfg,
+		db:       db,
+		stopChan: make(chan bool),
+	}
+}
+
+// SetWebSocketService устанавливает WebSocket сервис для логов
+func (s *HistoryService) SetWebSocketService(wsService *WebSocketService) {
+	s.wsService = wsService
+}
+
+// Start применяет правила хранения при запуске и затем раз в час
+func (s *HistoryService) Start() {
+	if !s.cfg.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	s.applyRetention()
+	for {
+		select {
+		case <-ticker.C:
+			s.applyRetention()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *HistoryService) Stop() {
+	s.stopChan <- true
+}
+
+// applyRetention переносит истекшие задачи в архив и пишет итог в лог
+func (s *HistoryService) applyRetention() {
+	result, err := s.ApplyRetention(false)
+	if err != nil {
+		log.Printf("Ошибка переноса истории в архив: %v", err)
+		return
+	}
+	if result.Removed > 0 {
+		message := fmt.Sprintf("История: перенесено в архив задач %d", result.Removed)
+		log.Println(message)
+		if s.wsService != nil {
+			s.wsService.BroadcastLog(message, "info")
+		}
+	}
+}
+
+// ApplyRetention переносит в архив задачи, истекшие по правилам history
+func (s *HistoryService) ApplyRetention(dryRun bool) (*PurgeResult, error) {
+	tasks, err := s.db.GetAllTasks()
+	if err != nil {
+		return nil, err
+	}
+	return s.remove(s.expired(tasks, time.Now()), false, dryRun)
+}
+
+// Purge удаляет из истории завершенные задачи, подходящие под фильтр query
+// (статусы, период, профиль, путь). Без discard задачи переносятся в архив
+func (s *HistoryService) Purge(query database.TaskQuery, discard, dryRun bool) (*PurgeResult, error) {
+	if len(query.Statuses) == 0 {
+		query.Statuses = models.FinishedStatuses
+	}
+	for _, status := range query.Statuses {
+		if !isFinishedStatus(status) {
+			return nil, fmt.Errorf("статус %q не относится к завершенным задачам", status)
+		}
+	}
+
+	var taskIDs []string
+	query.Limit = database.MaxPageSize
+	for {
+		page, err := s.db.ListTasks(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range page.Tasks {
+			taskIDs = append(taskIDs, task.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	return s.remove(taskIDs, discard, dryRun)
+}
+
+// OpenArchive открывает файл архива для выгрузки
+func (s *HistoryService) OpenArchive() (*os.File, error) {
+	return s.db.Archive().Open()
+}
+
+// remove переносит задачи в архив или удаляет их
+func (s *HistoryService) remove(taskIDs []string, discard, dryRun bool) (*PurgeResult, error) {
+	result := &PurgeResult{Matched: len(taskIDs), Archived: !discard, DryRun: dryRun}
+	if dryRun || len(taskIDs) == 0 {
+		return result, nil
+	}
+
+	var removed []*models.Task
+	var err error
+	if discard {
+		removed, err = s.db.PurgeTasks(taskIDs)
+	} else {
+		removed, err = s.db.ArchiveTasks(taskIDs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Removed = len(removed)
+	return result, nil
+}
+
+// expired выбирает завершенные задачи, которые больше не нужно хранить: старше KeepDays
+// (с ошибкой - старше KeepErrorDays) или за пределами KeepTasks последних задач без ошибки
+func (s *HistoryService) expired(tasks []*models.Task, now time.Time) []string {
+	var finished []*models.Task
+	for _, task := range tasks {
+		if !task.IsActive() {
+			finished = append(finished, task)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].ActivityAt().After(finished[j].ActivityAt())
+	})
+
+	var cutoff, errorCutoff time.Time
+	if s.cfg.KeepDays > 0 {
+		cutoff = now.AddDate(0, 0, -s.cfg.KeepDays)
+	}
+	if days := s.cfg.ErrorDays(); days > 0 {
+		errorCutoff = now.AddDate(0, 0, -days)
+	}
+
+	var expired []string
+	kept := 0
+	for _, task := range finished {
+		at := task.ActivityAt()
+		if task.Status == models.StatusError {
+			if !errorCutoff.IsZero() && at.Before(errorCutoff) {
+				expired = append(expired, task.ID)
+			}
+			continue
+		}
+
+		kept++
+		if (s.cfg.KeepTasks > 0 && kept > s.cfg.KeepTasks) || (!cutoff.IsZero() && at.Before(cutoff)) {
+			expired = append(expired, task.ID)
+		}
+	}
+	return expired
+}
+
+// isFinishedStatus проверяет, что статус конечный
+func isFinishedStatus(status models.TaskStatus) bool {
+	for _, finished := range models.FinishedStatuses {
+		if status == finished {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package services
+
+import "os"
+
+// fileInode в Windows недоступен, кэш проверяет только размер и время изменения
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
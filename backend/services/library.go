@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/database"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// libraryScanKey - итог последнего сканирования в служебном состоянии
+const libraryScanKey = "library_scan"
+
+// ScanState - состояние сканирования медиатеки
+type ScanState struct {
+	Running bool `json:"running"`
+	Force   bool `json:"force"`
+	// Total - найдено видеофайлов, Scanned - обработано, Probed - из них заново прочитано ffprobe
+	Total      int        `json:"total"`
+	Scanned    int        `json:"scanned"`
+	Probed     int        `json:"probed"`
+	Errors     int        `json:"errors"`
+	Removed    int        `json:"removed"`
+	Cancelled  bool       `json:"cancelled"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// InventoryQuery - фильтр описи медиатеки, пустые поля не учитываются
+type InventoryQuery struct {
+	Codec    string `json:"codec"`
+	Profile  string `json:"profile"`
+	Layout   string `json:"layout"`
+	Language string `json:"language"`
+	// AnyStream - искать среди всех дорожек, а не только первой (конвертируется первая)
+	AnyStream bool `json:"anyStream"`
+	// NotConverted - исключить файлы с успешно завершенной задачей
+	NotConverted bool `json:"notConverted"`
+	Limit        int  `json:"limit"`
+}
+
+// InventoryItem - файл описи вместе с последней задачей по нему
+type InventoryItem struct {
+	*models.MediaFile
+	TaskID     string            `json:"taskId,omitempty"`
+	TaskStatus models.TaskStatus `json:"taskStatus,omitempty"`
+}
+
+// LibraryScanner читает аудиодорожки всех видеофайлов медиатек ограниченным
+// числом процессов ffprobe и сохраняет опись в хранилище
+type LibraryScanner struct {
+	roots        []string
+	searchConfig config.SearchConfig
+	workers      int
+	db           *database.TaskRepository
+	cache        *ProbeCache
+	wsService    *WebSocketService
+
+	state  ScanState
+	cancel context.CancelFunc
+	mu     sync.Mutex
+}
+
+func NewLibraryScanner(roots []string, searchConfig config.SearchConfig, workers int, db *database.TaskRepository, cache *ProbeCache) *LibraryScanner {
+	s := &LibraryScanner{
+		roots:        roots,
+		searchConfig: searchConfig,
+		workers:      workers,
+		db:           db,
+		cache:        cache,
+	}
+
+	if _, err := db.GetState(libraryScanKey, &s.state); err != nil {
+		log.Printf("Ошибка чтения состояния сканирования: %v", err)
+	}
+	// Сканирование, прерванное перезапуском, не продолжается
+	s.state.Running = false
+
+	return s
+}
+
+func (s *LibraryScanner) SetWebSocketService(wsService *WebSocketService) {
+	s.wsService = wsService
+}
+
+// State возвращает состояние текущего или последнего сканирования
+func (s *LibraryScanner) State() ScanState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// StartScan запускает сканирование в фоне. Без force файлы, не изменившиеся
+// с прошлого чтения, берутся из кэша ffprobe
+func (s *LibraryScanner) StartScan(force bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state.Running {
+		return fmt.Errorf("сканирование уже выполняется")
+	}
+
+	now := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.state = ScanState{Running: true, Force: force, StartedAt: &now}
+
+	go s.run(ctx, force)
+	return nil
+}
+
+// CancelScan останавливает текущее сканирование
+func (s *LibraryScanner) CancelScan() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.state.Running {
+		return fmt.Errorf("сканирование не выполняется")
+	}
+	s.cancel()
+	return nil
+}
+
+func (s *LibraryScanner) run(ctx context.Context, force bool) {
+	log.Printf("Сканирование медиатеки: %s", strings.Join(s.roots, ", "))
+	s.broadcast(0, "Поиск видеофайлов", false)
+
+	files := s.collectFiles(ctx)
+
+	s.mu.Lock()
+	s.state.Total = len(files)
+	s.mu.Unlock()
+
+	inventory := s.db.Inventory()
+	jobs := make(chan string)
+	var scanned, probed, errors int64
+	var wg sync.WaitGroup
+
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				file, wasProbed := s.cache.ProbeFile(ctx, path, files[path], force)
+				if ctx.Err() != nil {
+					return
+				}
+				if wasProbed {
+					atomic.AddInt64(&probed, 1)
+				}
+				if file.ProbeError != "" {
+					atomic.AddInt64(&errors, 1)
+				}
+				atomic.AddInt64(&scanned, 1)
+			}
+		}()
+	}
+
+	// Прогресс отправляется раз в секунду, опись сохраняется автосохранением хранилища
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				current := int(atomic.LoadInt64(&scanned))
+				s.mu.Lock()
+				s.state.Scanned = current
+				s.state.Probed = int(atomic.LoadInt64(&probed))
+				s.state.Errors = int(atomic.LoadInt64(&errors))
+				s.mu.Unlock()
+
+				s.broadcast(percent(current, len(files)),
+					fmt.Sprintf("Просканировано %d из %d", current, len(files)), false)
+			}
+		}
+	}()
+
+feed:
+	for path := range files {
+		select {
+		case jobs <- path:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	close(done)
+
+	cancelled := ctx.Err() != nil
+	removed := 0
+	if !cancelled {
+		// Удаляем из описи файлы, которых больше нет
+		seen := make(map[string]bool, len(files))
+		for path := range files {
+			seen[path] = true
+		}
+		removed = inventory.Prune(s.roots, seen)
+	}
+	if err := inventory.Flush(); err != nil {
+		log.Printf("Ошибка сохранения описи медиатеки: %v", err)
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.state.Running = false
+	s.state.Scanned = int(scanned)
+	s.state.Probed = int(probed)
+	s.state.Errors = int(errors)
+	s.state.Removed = removed
+	s.state.Cancelled = cancelled
+	s.state.FinishedAt = &now
+	state := s.state
+	s.cancel()
+	s.mu.Unlock()
+
+	if err := s.db.SetState(libraryScanKey, state); err != nil {
+		log.Printf("Ошибка сохранения состояния сканирования: %v", err)
+	}
+
+	message := fmt.Sprintf("Сканирование завершено: %d файлов, прочитано %d, ошибок %d, удалено из описи %d",
+		state.Scanned, state.Probed, state.Errors, state.Removed)
+	if cancelled {
+		message = fmt.Sprintf("Сканирование отменено: обработано %d из %d", state.Scanned, state.Total)
+	}
+	log.Println(message)
+	s.broadcast(percent(state.Scanned, state.Total), message, true)
+}
+
+// collectFiles обходит медиатеки и возвращает видеофайлы с их атрибутами. Скрытые файлы
+// и директории (в том числе временные результаты FFmpeg) и search.excludeDirs пропускаются
+func (s *LibraryScanner) collectFiles(ctx context.Context) map[string]os.FileInfo {
+	files := make(map[string]os.FileInfo)
+	for _, root := range s.roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil || path == root {
+				return nil
+			}
+			if strings.HasPrefix(info.Name(), ".") {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() {
+				if s.searchConfig.Excluded(path) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !isVideoFile(path) {
+				return nil
+			}
+			files[path] = info
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Ошибка обхода %s: %v", root, err)
+		}
+	}
+	return files
+}
+
+// Query возвращает файлы описи, подходящие под фильтр, и их общее число без учета Limit
+func (s *LibraryScanner) Query(query InventoryQuery) ([]InventoryItem, int) {
+	tasks := s.db.LatestTasksByFilePath()
+
+	items := []InventoryItem{}
+	total := 0
+	for _, file := range s.db.Inventory().All() {
+		if !matchesInventoryQuery(file, query) {
+			continue
+		}
+
+		item := InventoryItem{MediaFile: file}
+		if task, exists := tasks[file.Path]; exists {
+			if query.NotConverted && task.Status == models.StatusCompleted {
+				continue
+			}
+			item.TaskID = task.ID
+			item.TaskStatus = task.Status
+		}
+
+		total++
+		if query.Limit <= 0 || len(items) < query.Limit {
+			items = append(items, item)
+		}
+	}
+
+	return items, total
+}
+
+// matchesInventoryQuery проверяет, есть ли в файле дорожка, подходящая под фильтр
+func matchesInventoryQuery(file *models.MediaFile, query InventoryQuery) bool {
+	if query.Codec == "" && query.Profile == "" && query.Layout == "" && query.Language == "" {
+		return true
+	}
+
+	streams := file.Audio
+	if !query.AnyStream && len(streams) > 1 {
+		streams = streams[:1]
+	}
+
+	for _, stream := range streams {
+		if query.Codec != "" && !strings.EqualFold(stream.CodecName, query.Codec) {
+			continue
+		}
+		if query.Profile != "" && !strings.EqualFold(stream.Profile, query.Profile) {
+			continue
+		}
+		// 5.1 подходит и для 5.1(side)
+		if query.Layout != "" && stream.ChannelLayout != query.Layout &&
+			!strings.HasPrefix(stream.ChannelLayout, query.Layout+"(") {
+			continue
+		}
+		if query.Language != "" && !strings.EqualFold(stream.Language, query.Language) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func (s *LibraryScanner) broadcast(progress int, message string, completed bool) {
+	if s.wsService != nil {
+		s.wsService.BroadcastScanProgress(progress, message, completed)
+	}
+}
+
+// percent возвращает долю done от total в процентах
+func percent(done, total int) int {
+	if total == 0 {
+		return 100
+	}
+	return done * 100 / total
+}
+
+// ffprobeInventory - ответ ffprobe со всеми аудиодорожками и длительностью
+type ffprobeInventory struct {
+	Streams []struct {
+		CodecName     string            `json:"codec_name"`
+		Profile       string            `json:"profile"`
+		ChannelLayout string            `json:"channel_layout"`
+		Channels      int               `json:"channels"`
+		SampleRate    string            `json:"sample_rate"`
+		BitRate       string            `json:"bit_rate"`
+		Tags          map[string]string `json:"tags"`
+		Disposition   map[string]int    `json:"disposition"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+// probeMediaFile читает все аудиодорожки файла. Ошибка ffprobe сохраняется в записи
+func probeMediaFile(ctx context.Context, path string, info os.FileInfo) *models.MediaFile {
+	file := &models.MediaFile{
+		Path:      path,
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		Inode:     fileInode(info),
+		Audio:     []models.AudioStream{},
+		ScannedAt: time.Now(),
+	}
+
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_streams",
+		"-show_format",
+		"-select_streams", "a",
+		path,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		file.ProbeError = err.Error()
+		return file
+	}
+
+	var probe ffprobeInventory
+	if err := json.Unmarshal(output, &probe); err != nil {
+		file.ProbeError = err.Error()
+		return file
+	}
+
+	file.Duration, _ = strconv.ParseFloat(probe.Format.Duration, 64)
+	for i, stream := range probe.Streams {
+		file.Audio = append(file.Audio, models.AudioStream{
+			Index:         i,
+			CodecName:     stream.CodecName,
+			Profile:       stream.Profile,
+			ChannelLayout: stream.ChannelLayout,
+			Channels:      stream.Channels,
+			SampleRate:    stream.SampleRate,
+			BitRate:       stream.BitRate,
+			Language:      stream.Tags["language"],
+			Title:         stream.Tags["title"],
+			Default:       stream.Disposition["default"] == 1,
+		})
+	}
+
+	return file
+}
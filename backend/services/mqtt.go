@@ -0,0 +1,289 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/models"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	mqttQoS = 1
+
+	mqttCommandPause  = "pause"
+	mqttCommandResume = "resume"
+
+	mqttOnline  = "online"
+	mqttOffline = "offline"
+)
+
+// MQTTService зеркалирует состояние очереди и прогресс конвертации в retained топики
+// MQTT брокера и принимает команды паузы очереди
+type MQTTService struct {
+	cfg          config.MQTTConfig
+	queueService *QueueService
+	client       mqtt.Client
+
+	// Последние опубликованные значения по топикам, повторяются после переподключения
+	retained map[string][]byte
+	mu       sync.Mutex
+}
+
+// mqttQueueState - содержимое топика queue
+type mqttQueueState struct {
+	Length     int  `json:"length"`
+	Processing int  `json:"processing"`
+	Paused     bool `json:"paused"`
+}
+
+// mqttProgress - содержимое топика progress
+type mqttProgress struct {
+	TaskID   string            `json:"taskId"`
+	Current  string            `json:"current"`
+	FilePath string            `json:"filePath"`
+	Status   models.TaskStatus `json:"status"`
+	Progress int               `json:"progress"`
+	Message  string            `json:"message"`
+}
+
+// mqttError - содержимое топика last_error
+type mqttError struct {
+	TaskID   string    `json:"taskId"`
+	FilePath string    `json:"filePath"`
+	Error    string    `json:"error"`
+	At       time.Time `json:"at"`
+}
+
+func NewMQTTService(cfg config.MQTTConfig, queueService *QueueService) *MQTTService {
+	return &MQTTService{
+		cfg:          cfg,
+		queueService: queueService,
+		retained:     make(map[string][]byte),
+	}
+}
+
+// Start подключается к брокеру. Подключение и переподключения выполняются в фоне,
+// недоступный брокер не мешает работе сервера
+func (s *MQTTService) Start() {
+	opts := mqtt.NewClientOptions().
+		AddBroker(s.cfg.Broker).
+		SetClientID(s.cfg.ClientID).
+		SetUsername(s.cfg.Username).
+		SetPassword(s.cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(10*time.Second).
+		SetWill(s.topic("status"), mqttOffline, mqttQoS, true).
+		SetOnConnectHandler(s.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("MQTT: соединение с брокером потеряно: %v", err)
+		})
+
+	s.client = mqtt.NewClient(opts)
+	s.client.Connect()
+	log.Printf("MQTT: подключение к %s", s.cfg.Broker)
+}
+
+// onConnect публикует доступность, автообнаружение и последнее состояние,
+// затем подписывается на топик команд
+func (s *MQTTService) onConnect(client mqtt.Client) {
+	log.Printf("MQTT: подключено к %s", s.cfg.Broker)
+
+	client.Publish(s.topic("status"), mqttQoS, true, mqttOnline)
+	if s.cfg.Discovery {
+		s.publishDiscovery(client)
+	}
+
+	s.mu.Lock()
+	for topic, payload := range s.retained {
+		client.Publish(topic, mqttQoS, true, payload)
+	}
+	s.mu.Unlock()
+
+	token := client.Subscribe(s.topic("command"), mqttQoS, s.handleCommand)
+	if token.Wait() && token.Error() != nil {
+		log.Printf("MQTT: ошибка подписки на команды: %v", token.Error())
+	}
+}
+
+// handleCommand выполняет команду pause или resume очереди
+func (s *MQTTService) handleCommand(_ mqtt.Client, msg mqtt.Message) {
+	command := strings.ToLower(strings.TrimSpace(string(msg.Payload())))
+
+	var err error
+	switch command {
+	case mqttCommandPause:
+		err = s.queueService.PauseQueue()
+	case mqttCommandResume:
+		err = s.queueService.ResumeQueue()
+	default:
+		log.Printf("MQTT: неизвестная команда %q", command)
+		return
+	}
+
+	if err != nil {
+		log.Printf("MQTT: ошибка выполнения команды %s: %v", command, err)
+	} else {
+		log.Printf("MQTT: выполнена команда %s", command)
+	}
+}
+
+// PublishQueue публикует длину очереди по списку задач из BroadcastQueueUpdate
+func (s *MQTTService) PublishQueue(tasks []*models.Task) {
+	state := mqttQueueState{Paused: s.queueService.IsPaused()}
+	for _, task := range tasks {
+		switch task.Status {
+		case models.StatusPending:
+			state.Length++
+		case models.StatusProcessing, models.StatusPaused:
+			state.Processing++
+		}
+	}
+
+	s.publishJSON("queue", state)
+}
+
+// PublishQueueState обновляет флаг паузы очереди
+func (s *MQTTService) PublishQueueState() {
+	tasks, err := s.queueService.GetQueue()
+	if err != nil {
+		log.Printf("MQTT: ошибка получения очереди: %v", err)
+		return
+	}
+	s.PublishQueue(tasks)
+}
+
+// PublishProgress публикует прогресс из BroadcastConversionProgress,
+// ошибки задач дополнительно попадают в last_error
+func (s *MQTTService) PublishProgress(taskID string, progress int, status models.TaskStatus, message string) {
+	payload := mqttProgress{
+		TaskID:   taskID,
+		Current:  "idle",
+		Status:   status,
+		Progress: progress,
+		Message:  message,
+	}
+
+	if task, err := s.queueService.GetTask(taskID); err == nil && task != nil {
+		payload.FilePath = task.FilePath
+		if status == models.StatusProcessing || status == models.StatusPaused {
+			payload.Current = filepath.Base(task.FilePath)
+		}
+	}
+
+	s.publishJSON("progress", payload)
+
+	if status == models.StatusError {
+		s.publishJSON("last_error", mqttError{
+			TaskID:   taskID,
+			FilePath: payload.FilePath,
+			Error:    message,
+			At:       time.Now(),
+		})
+	}
+}
+
+// publishJSON публикует retained сообщение, если оно изменилось
+func (s *MQTTService) publishJSON(name string, v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("MQTT: ошибка сериализации %s: %v", name, err)
+		return
+	}
+
+	topic := s.topic(name)
+
+	s.mu.Lock()
+	if string(s.retained[topic]) == string(payload) {
+		s.mu.Unlock()
+		return
+	}
+	s.retained[topic] = payload
+	s.mu.Unlock()
+
+	// Без соединения значение будет опубликовано в onConnect
+	if s.client != nil && s.client.IsConnectionOpen() {
+		s.client.Publish(topic, mqttQoS, true, payload)
+	}
+}
+
+// publishDiscovery публикует конфигурацию сущностей Home Assistant
+func (s *MQTTService) publishDiscovery(client mqtt.Client) {
+	nodeID := strings.ReplaceAll(s.cfg.TopicPrefix, "/", "_")
+	device := map[string]interface{}{
+		"identifiers":  []string{nodeID},
+		"name":         "DTS to FLAC Converter",
+		"manufacturer": "ultimate-dts-fix-server",
+	}
+
+	entities := []struct {
+		component string
+		object    string
+		config    map[string]interface{}
+	}{
+		{"sensor", "current_conversion", map[string]interface{}{
+			"name":                  "Current conversion",
+			"state_topic":           s.topic("progress"),
+			"value_template":        "{{ value_json.current }}",
+			"json_attributes_topic": s.topic("progress"),
+			"icon":                  "mdi:file-video",
+		}},
+		{"sensor", "progress", map[string]interface{}{
+			"name":                "Progress",
+			"state_topic":         s.topic("progress"),
+			"value_template":      "{{ value_json.progress if value_json.current != 'idle' else 0 }}",
+			"unit_of_measurement": "%",
+			"icon":                "mdi:progress-clock",
+		}},
+		{"sensor", "queue_length", map[string]interface{}{
+			"name":                  "Queue length",
+			"state_topic":           s.topic("queue"),
+			"value_template":        "{{ value_json.length }}",
+			"json_attributes_topic": s.topic("queue"),
+			"icon":                  "mdi:playlist-play",
+		}},
+		{"sensor", "last_error", map[string]interface{}{
+			"name":                  "Last error",
+			"state_topic":           s.topic("last_error"),
+			"value_template":        "{{ value_json.error[:250] }}",
+			"json_attributes_topic": s.topic("last_error"),
+			"icon":                  "mdi:alert-circle",
+		}},
+		{"switch", "queue_paused", map[string]interface{}{
+			"name":           "Queue paused",
+			"state_topic":    s.topic("queue"),
+			"value_template": fmt.Sprintf("{{ '%s' if value_json.paused else '%s' }}", mqttCommandPause, mqttCommandResume),
+			"command_topic":  s.topic("command"),
+			"payload_on":     mqttCommandPause,
+			"payload_off":    mqttCommandResume,
+			"icon":           "mdi:pause-circle",
+		}},
+	}
+
+	for _, entity := range entities {
+		entity.config["unique_id"] = nodeID + "_" + entity.object
+		entity.config["availability_topic"] = s.topic("status")
+		entity.config["device"] = device
+
+		payload, err := json.Marshal(entity.config)
+		if err != nil {
+			log.Printf("MQTT: ошибка сериализации автообнаружения: %v", err)
+			continue
+		}
+
+		topic := fmt.Sprintf("%s/%s/%s/%s/config", s.cfg.DiscoveryPrefix, entity.component, nodeID, entity.object)
+		client.Publish(topic, mqttQoS, true, payload)
+	}
+}
+
+// topic возвращает полное имя топика с префиксом
+func (s *MQTTService) topic(name string) string {
+	return s.cfg.TopicPrefix + "/" + name
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+var (
+	templateVarRe = regexp.MustCompile(`\{([A-Za-z]+)\}`)
+	yearRe        = regexp.MustCompile(`(?:^|[.\s_(\[-])((?:19|20)\d{2})(?:$|[.\s_)\]-])`)
+	resolutionRe  = regexp.MustCompile(`(?i)(?:^|[.\s_(\[-])(4320p|2160p|1440p|1080[pi]|720p|576[pi]|480[pi]|4k|uhd)(?:$|[.\s_)\]-])`)
+)
+
+// errOutputExists - итоговое имя занято, а политика профиля запрещает его занимать
+type errOutputExists struct {
+	path   string
+	policy string
+}
+
+func (e *errOutputExists) Error() string {
+	if e.policy == config.CollisionSkip {
+		return fmt.Sprintf("пропущено: файл %s уже существует", e.path)
+	}
+	return fmt.Sprintf("файл %s уже существует", e.path)
+}
+
+// namingVariables собирает переменные шаблона имени для задачи
+func namingVariables(inputPath string, audio *models.AudioInfo, profile *config.ProfileConfig) map[string]string {
+	filename := filepath.Base(inputPath)
+	stem := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+	vars := map[string]string{
+		"stem":         stem,
+		"targetCodec":  profile.TargetCodec,
+		"targetLayout": profile.TargetLayout,
+		"profile":      profile.Name,
+	}
+
+	if audio != nil {
+		vars["sourceCodec"] = strings.ToUpper(audio.CodecName)
+		vars["sourceLayout"] = audio.ChannelLayout
+	}
+	if match := yearRe.FindStringSubmatch(stem); match != nil {
+		vars["year"] = match[1]
+	}
+	if match := resolutionRe.FindStringSubmatch(stem); match != nil {
+		vars["resolution"] = match[1]
+	}
+
+	return vars
+}
+
+// renderTemplate подставляет переменные вида {name}, неизвестные заменяются пустой строкой
+func renderTemplate(template string, vars map[string]string) string {
+	return templateVarRe.ReplaceAllStringFunc(template, func(match string) string {
+		return vars[match[1:len(match)-1]]
+	})
+}
+
+// buildOutputName возвращает имя выходного файла без учета коллизий
+func buildOutputName(inputPath string, audio *models.AudioInfo, profile *config.ProfileConfig) string {
+	vars := namingVariables(inputPath, audio, profile)
+	name := renderTemplate(profile.Naming.Template, vars)
+
+	for i := range profile.Naming.Replace {
+		rule := &profile.Naming.Replace[i]
+		// Выражения компилируются при загрузке конфигурации
+		if re := rule.Regexp(); re != nil {
+			name = re.ReplaceAllString(name, renderTemplate(rule.Replacement, vars))
+		}
+	}
+
+	// Имя не должно уводить файл в другую директорию
+	name = strings.Trim(strings.ReplaceAll(name, string(filepath.Separator), "_"), " .")
+	if name == "" {
+		name = vars["stem"]
+	}
+
+	return name + filepath.Ext(inputPath)
+}
+
+// resolveOutputPath применяет политику коллизий профиля к имени выходного файла
+func resolveOutputPath(inputPath string, audio *models.AudioInfo, profile *config.ProfileConfig) (string, error) {
+	dir := filepath.Dir(inputPath)
+	filename := buildOutputName(inputPath, audio, profile)
+	outputPath := filepath.Join(dir, filename)
+
+	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
+		return outputPath, nil
+	}
+
+	switch profile.Naming.Collision {
+	case config.CollisionOverwrite:
+		// Исходный файл перезаписывать нельзя, для него всегда добавляется суффикс
+		if outputPath != inputPath {
+			return outputPath, nil
+		}
+	case config.CollisionSkip, config.CollisionFail:
+		return outputPath, &errOutputExists{path: outputPath, policy: profile.Naming.Collision}
+	}
+
+	// Добавляем суффикс если файл уже существует
+	ext := filepath.Ext(filename)
+	baseName := strings.TrimSuffix(filename, ext)
+	for counter := 1; ; counter++ {
+		outputPath = filepath.Join(dir, fmt.Sprintf("%s_%d%s", baseName, counter, ext))
+		if _, err := os.Stat(outputPath); os.IsNotExist(err) {
+			return outputPath, nil
+		}
+	}
+}
+
+// isSkipped возвращает true, если ошибка означает пропуск задачи по политике коллизий
+func isSkipped(err error) bool {
+	var exists *errOutputExists
+	return errors.As(err, &exists) && exists.policy == config.CollisionSkip
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// testProfile возвращает профиль с правилами naming, прошедший проверку конфигурации
+func testProfile(t *testing.T, naming config.NamingConfig) *config.ProfileConfig {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Profiles = []config.ProfileConfig{{Name: "test", TargetCodec: "FLAC", TargetLayout: "7.1", Naming: naming}}
+	cfg.DefaultProfile = "test"
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := cfg.Profile("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile
+}
+
+func writeTestFile(t *testing.T, path string, size int) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// dtsToFlac - замена из профиля по умолчанию
+var dtsToFlac = config.ReplaceRule{Pattern: `DTS[.\-A-Za-z]*5\.1`, Replacement: "{targetCodec}.{targetLayout}"}
+
+func TestBuildOutputName(t *testing.T) {
+	audio := &models.AudioInfo{CodecName: "dts", ChannelLayout: "5.1(side)"}
+
+	tests := []struct {
+		name     string
+		input    string
+		template string
+		replace  []config.ReplaceRule
+		want     string
+	}{
+		{
+			name:    "default replace",
+			input:   "Movie.2019.1080p.DTS-HD.MA.5.1-GRP.mkv",
+			replace: []config.ReplaceRule{dtsToFlac},
+			want:    "Movie.2019.1080p.FLAC.7.1-GRP.mkv",
+		},
+		{
+			name:     "template variables",
+			input:    "Movie.mkv",
+			template: "{stem} [{sourceCodec} {sourceLayout} to {targetCodec} {targetLayout}]",
+			want:     "Movie [DTS 5.1(side) to FLAC 7.1].mkv",
+		},
+		{
+			name:     "year and resolution",
+			input:    "Film.2021.2160p.DTS.mkv",
+			template: "{year} - {resolution} - {profile}",
+			want:     "2021 - 2160p - test.mkv",
+		},
+		{
+			name:     "unknown variable",
+			input:    "Movie.mkv",
+			template: "{stem}{unknown}",
+			want:     "Movie.mkv",
+		},
+		{
+			name:     "separator",
+			input:    "Movie.mkv",
+			template: "{stem}/extra",
+			want:     "Movie_extra.mkv",
+		},
+		{
+			name:     "empty result falls back to stem",
+			input:    "Movie.mkv",
+			template: " {unknown}. ",
+			want:     "Movie.mkv",
+		},
+		{
+			name:    "replacement with group",
+			input:   "Movie 2019.mkv",
+			replace: []config.ReplaceRule{{Pattern: `(\d{4})$`, Replacement: "($1)"}},
+			want:    "Movie (2019).mkv",
+		},
+		{
+			name:  "rules applied in order",
+			input: "Movie.DTS.mkv",
+			replace: []config.ReplaceRule{
+				{Pattern: `DTS`, Replacement: "{targetCodec}"},
+				{Pattern: `FLAC`, Replacement: "FLAC.{targetLayout}"},
+			},
+			want: "Movie.FLAC.7.1.mkv",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile := testProfile(t, config.NamingConfig{Template: tt.template, Replace: tt.replace})
+			if got := buildOutputName(filepath.Join("/media", tt.input), audio, profile); got != tt.want {
+				t.Errorf("buildOutputName(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvalidReplacePatternRejected(t *testing.T) {
+	cfg := config.Default()
+	cfg.Profiles = []config.ProfileConfig{{
+		Name:   "broken",
+		Naming: config.NamingConfig{Replace: []config.ReplaceRule{{Pattern: `DTS(`}}},
+	}}
+	cfg.DefaultProfile = "broken"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("Validate() accepted an invalid replace pattern")
+	}
+}
+
+func TestResolveOutputPath(t *testing.T) {
+	const input = "Movie.DTS.5.1.mkv"
+
+	tests := []struct {
+		name      string
+		collision string
+		// template и replace по умолчанию дают Movie.FLAC.7.1.mkv
+		template string
+		noRules  bool
+		existing []string
+		want     string
+		// wantErr - ошибка коллизии, wantSkipped - задача пропускается, а не падает
+		wantErr     bool
+		wantSkipped bool
+	}{
+		{name: "free name", collision: config.CollisionSuffix, want: "Movie.FLAC.7.1.mkv"},
+		{name: "free name with fail", collision: config.CollisionFail, want: "Movie.FLAC.7.1.mkv"},
+		{
+			name:      "suffix",
+			collision: config.CollisionSuffix,
+			existing:  []string{"Movie.FLAC.7.1.mkv"},
+			want:      "Movie.FLAC.7.1_1.mkv",
+		},
+		{
+			name:      "suffix skips taken numbers",
+			collision: config.CollisionSuffix,
+			existing:  []string{"Movie.FLAC.7.1.mkv", "Movie.FLAC.7.1_1.mkv"},
+			want:      "Movie.FLAC.7.1_2.mkv",
+		},
+		{
+			name:      "overwrite",
+			collision: config.CollisionOverwrite,
+			existing:  []string{"Movie.FLAC.7.1.mkv"},
+			want:      "Movie.FLAC.7.1.mkv",
+		},
+		{
+			name:      "overwrite never targets the source",
+			collision: config.CollisionOverwrite,
+			template:  "{stem}",
+			noRules:   true,
+			want:      "Movie.DTS.5.1_1.mkv",
+		},
+		{
+			name:        "skip",
+			collision:   config.CollisionSkip,
+			existing:    []string{"Movie.FLAC.7.1.mkv"},
+			want:        "Movie.FLAC.7.1.mkv",
+			wantErr:     true,
+			wantSkipped: true,
+		},
+		{
+			name:      "fail",
+			collision: config.CollisionFail,
+			existing:  []string{"Movie.FLAC.7.1.mkv"},
+			want:      "Movie.FLAC.7.1.mkv",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			naming := config.NamingConfig{Template: tt.template, Collision: tt.collision}
+			if !tt.noRules {
+				naming.Replace = []config.ReplaceRule{dtsToFlac}
+			}
+			profile := testProfile(t, naming)
+
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, input), 10)
+			for _, name := range tt.existing {
+				writeTestFile(t, filepath.Join(dir, name), 10)
+			}
+
+			got, err := resolveOutputPath(filepath.Join(dir, input), nil, profile)
+			if want := filepath.Join(dir, tt.want); got != want {
+				t.Errorf("resolveOutputPath() = %q, want %q", got, want)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveOutputPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if skipped := isSkipped(err); skipped != tt.wantSkipped {
+				t.Errorf("isSkipped(%v) = %v, want %v", err, skipped, tt.wantSkipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+const (
+	NotificationPending = "pending"
+	NotificationOK      = "ok"
+	NotificationFailed  = "failed"
+)
+
+// NotifierService просит медиасерверы пересканировать директорию после конвертации
+type NotifierService struct {
+	notifiers    []config.NotifierConfig
+	queueService *QueueService
+	wsService    *WebSocketService
+	mu           sync.Mutex
+}
+
+func NewNotifierService(notifiers []config.NotifierConfig, queueService *QueueService) *NotifierService {
+	return &NotifierService{
+		notifiers:    notifiers,
+		queueService: queueService,
+	}
+}
+
+func (s *NotifierService) SetWebSocketService(wsService *WebSocketService) {
+	s.wsService = wsService
+}
+
+// NotifyTask асинхронно уведомляет все медиасерверы о директории задачи
+// и сохраняет статус каждого уведомления в задаче
+func (s *NotifierService) NotifyTask(task *models.Task) {
+	if len(s.notifiers) == 0 {
+		return
+	}
+
+	dir := filepath.Dir(task.FilePath)
+
+	s.mu.Lock()
+	task.Notifications = nil
+	for _, notifier := range s.notifiers {
+		task.Notifications = append(task.Notifications, models.NotificationStatus{
+			Notifier: notifier.Name,
+			Status:   NotificationPending,
+			At:       time.Now(),
+		})
+	}
+	s.mu.Unlock()
+
+	for i, notifier := range s.notifiers {
+		go func(index int, notifier config.NotifierConfig) {
+			attempts, err := s.deliver(notifier, dir)
+			s.recordStatus(task, index, attempts, err)
+		}(i, notifier)
+	}
+}
+
+// TestNotifier синхронно отправляет уведомление одному медиасерверу
+func (s *NotifierService) TestNotifier(name, dir string) (int, error) {
+	for _, notifier := range s.notifiers {
+		if notifier.Name == name {
+			return s.deliver(notifier, dir)
+		}
+	}
+	return 0, fmt.Errorf("уведомление %q не настроено", name)
+}
+
+// recordStatus сохраняет итог уведомления в задаче
+func (s *NotifierService) recordStatus(task *models.Task, index, attempts int, err error) {
+	s.mu.Lock()
+	if index >= len(task.Notifications) {
+		s.mu.Unlock()
+		return
+	}
+	status := &task.Notifications[index]
+	status.Attempts = attempts
+	status.At = time.Now()
+	if err != nil {
+		status.Status = NotificationFailed
+		status.Error = err.Error()
+	} else {
+		status.Status = NotificationOK
+	}
+	name := status.Notifier
+	s.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Уведомление %s для задачи %s не доставлено: %v", name, task.ID, err)
+		if s.wsService != nil {
+			s.wsService.BroadcastLog(fmt.Sprintf("Медиасервер %s не уведомлен: %v", name, err), "warning")
+		}
+	} else {
+		log.Printf("Медиасервер %s уведомлен о задаче %s", name, task.ID)
+	}
+
+	if err := s.queueService.UpdateTask(task); err != nil {
+		log.Printf("Ошибка сохранения статуса уведомления: %v", err)
+	}
+}
+
+// deliver отправляет уведомление с повторами и возвращает число попыток
+func (s *NotifierService) deliver(notifier config.NotifierConfig, dir string) (int, error) {
+	client := &http.Client{Timeout: time.Duration(notifier.TimeoutSeconds * float64(time.Second))}
+	delay := time.Duration(notifier.RetryDelaySeconds * float64(time.Second))
+	serverDir := config.MapPath(dir, notifier.PathMap)
+
+	var err error
+	attempts := 0
+	for attempt := 0; attempt <= *notifier.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		attempts++
+
+		var req *http.Request
+		req, err = buildNotifierRequest(notifier, serverDir)
+		if err != nil {
+			// Некорректный запрос не исправится повтором
+			return attempts, err
+		}
+
+		if err = doNotifierRequest(client, req); err == nil {
+			return attempts, nil
+		}
+	}
+
+	return attempts, err
+}
+
+// buildNotifierRequest формирует запрос на пересканирование директории
+func buildNotifierRequest(notifier config.NotifierConfig, dir string) (*http.Request, error) {
+	baseURL := strings.TrimSuffix(notifier.URL, "/")
+
+	switch notifier.Type {
+	case config.NotifierPlex:
+		query := url.Values{}
+		query.Set("path", dir)
+		query.Set("X-Plex-Token", notifier.Token)
+		endpoint := fmt.Sprintf("%s/library/sections/%s/refresh?%s",
+			baseURL, url.PathEscape(notifier.SectionID), query.Encode())
+		return http.NewRequest(http.MethodGet, endpoint, nil)
+
+	case config.NotifierJellyfin, config.NotifierEmby:
+		body, err := json.Marshal(map[string]interface{}{
+			"Updates": []map[string]string{
+				{"Path": dir, "UpdateType": "Modified"},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/Library/Media/Updated", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if notifier.Type == config.NotifierJellyfin {
+			req.Header.Set("Authorization", fmt.Sprintf(`MediaBrowser Token="%s"`, notifier.Token))
+		} else {
+			req.Header.Set("X-Emby-Token", notifier.Token)
+		}
+		return req, nil
+	}
+
+	return nil, fmt.Errorf("неизвестный тип медиасервера %q", notifier.Type)
+}
+
+// doNotifierRequest выполняет запрос и считает ошибкой любой ответ кроме 2xx
+func doNotifierRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		// URL может содержать токен Plex, в статус задачи он попасть не должен
+		if urlErr, ok := err.(*url.Error); ok {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
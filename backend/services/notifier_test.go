@@ -0,0 +1,212 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"ultimate-dts-fix-server/backend/config"
+)
+
+const testToken = "secret-token"
+
+// notifierRequest - запрос, полученный тестовым медиасервером
+type notifierRequest struct {
+	method string
+	path   string
+	query  map[string]string
+	header http.Header
+	body   []byte
+}
+
+// notifierServer отвечает статусами из statuses по очереди, после них - 200
+type notifierServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []notifierRequest
+}
+
+func newNotifierServer(t *testing.T, statuses ...int) *notifierServer {
+	t.Helper()
+	s := &notifierServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		query := make(map[string]string)
+		for key := range r.URL.Query() {
+			query[key] = r.URL.Query().Get(key)
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, notifierRequest{r.Method, r.URL.Path, query, r.Header.Clone(), body})
+		status := http.StatusOK
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		s.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *notifierServer) received() []notifierRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]notifierRequest(nil), s.requests...)
+}
+
+// testNotifier возвращает настройки без задержки между повторами
+func testNotifier(notifierType, serverURL string, retries int) config.NotifierConfig {
+	return config.NotifierConfig{
+		Name:              notifierType,
+		Type:              notifierType,
+		URL:               serverURL,
+		Token:             testToken,
+		SectionID:         "3",
+		PathMap:           []config.PathMapping{{From: "/data", To: "/media"}},
+		Retries:           &retries,
+		RetryDelaySeconds: 0.001,
+		TimeoutSeconds:    5,
+	}
+}
+
+func TestNotifierRequests(t *testing.T) {
+	tests := []struct {
+		notifierType string
+		check        func(t *testing.T, req notifierRequest)
+	}{
+		{
+			notifierType: config.NotifierPlex,
+			check: func(t *testing.T, req notifierRequest) {
+				if req.method != http.MethodGet || req.path != "/library/sections/3/refresh" {
+					t.Errorf("request = %s %s, want GET /library/sections/3/refresh", req.method, req.path)
+				}
+				if req.query["path"] != "/media/Movies/Movie" {
+					t.Errorf("path = %q, want /media/Movies/Movie", req.query["path"])
+				}
+				if req.query["X-Plex-Token"] != testToken {
+					t.Errorf("X-Plex-Token = %q, want %q", req.query["X-Plex-Token"], testToken)
+				}
+			},
+		},
+		{
+			notifierType: config.NotifierJellyfin,
+			check: func(t *testing.T, req notifierRequest) {
+				checkMediaUpdated(t, req)
+				if want := `MediaBrowser Token="` + testToken + `"`; req.header.Get("Authorization") != want {
+					t.Errorf("Authorization = %q, want %q", req.header.Get("Authorization"), want)
+				}
+			},
+		},
+		{
+			notifierType: config.NotifierEmby,
+			check: func(t *testing.T, req notifierRequest) {
+				checkMediaUpdated(t, req)
+				if req.header.Get("X-Emby-Token") != testToken {
+					t.Errorf("X-Emby-Token = %q, want %q", req.header.Get("X-Emby-Token"), testToken)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.notifierType, func(t *testing.T) {
+			server := newNotifierServer(t)
+			service := NewNotifierService([]config.NotifierConfig{testNotifier(tt.notifierType, server.URL, 3)}, nil)
+
+			attempts, err := service.TestNotifier(tt.notifierType, "/data/Movies/Movie")
+			if err != nil || attempts != 1 {
+				t.Fatalf("TestNotifier() = %d, %v, want 1 attempt", attempts, err)
+			}
+			requests := server.received()
+			if len(requests) != 1 {
+				t.Fatalf("server got %d requests, want 1", len(requests))
+			}
+			tt.check(t, requests[0])
+		})
+	}
+}
+
+// checkMediaUpdated проверяет запрос Jellyfin/Emby на обновление директории
+func checkMediaUpdated(t *testing.T, req notifierRequest) {
+	t.Helper()
+	if req.method != http.MethodPost || req.path != "/Library/Media/Updated" {
+		t.Errorf("request = %s %s, want POST /Library/Media/Updated", req.method, req.path)
+	}
+	if req.header.Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", req.header.Get("Content-Type"))
+	}
+
+	var body struct {
+		Updates []struct {
+			Path       string
+			UpdateType string
+		}
+	}
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatalf("body %s: %v", req.body, err)
+	}
+	if len(body.Updates) != 1 || body.Updates[0].Path != "/media/Movies/Movie" || body.Updates[0].UpdateType != "Modified" {
+		t.Errorf("body = %s, want one Modified update for /media/Movies/Movie", req.body)
+	}
+}
+
+func TestNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		statuses     []int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "retry after 5xx", retries: 3, statuses: []int{503, 500}, wantAttempts: 3},
+		{name: "retries exhausted", retries: 2, statuses: []int{500, 502, 503, 504}, wantAttempts: 3, wantErr: true},
+		{name: "no retries", retries: 0, statuses: []int{503}, wantAttempts: 1, wantErr: true},
+		{name: "no retries on success", retries: 0, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newNotifierServer(t, tt.statuses...)
+			service := NewNotifierService([]config.NotifierConfig{testNotifier(config.NotifierJellyfin, server.URL, tt.retries)}, nil)
+
+			attempts, err := service.TestNotifier(config.NotifierJellyfin, "/data/Movies/Movie")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TestNotifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if got := len(server.received()); got != tt.wantAttempts {
+				t.Errorf("server got %d requests, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestNotifierErrorHidesPlexToken(t *testing.T) {
+	failing := newNotifierServer(t, http.StatusInternalServerError)
+	// Закрытый сервер дает ошибку соединения, текст которой у net/http содержит URL
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	for name, serverURL := range map[string]string{"http error": failing.URL, "connection error": closed.URL} {
+		t.Run(name, func(t *testing.T) {
+			service := NewNotifierService([]config.NotifierConfig{testNotifier(config.NotifierPlex, serverURL, 0)}, nil)
+
+			// Текст ошибки сохраняется в статусе уведомления задачи
+			_, err := service.TestNotifier(config.NotifierPlex, "/data/Movies/Movie")
+			if err == nil {
+				t.Fatal("TestNotifier() succeeded, want error")
+			}
+			if strings.Contains(err.Error(), testToken) {
+				t.Errorf("error %q contains the Plex token", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// durationTolerance - допустимое расхождение длительности результата с исходником
+const (
+	durationToleranceSeconds = 2.0
+	durationTolerancePercent = 0.5
+)
+
+// tempOutputPath возвращает скрытое имя временного файла для конвертации.
+// Расширение сохраняется, чтобы FFmpeg выбрал правильный контейнер
+func tempOutputPath(scratchDir, outputPath, taskID string) string {
+	dir := filepath.Dir(outputPath)
+	if scratchDir != "" {
+		dir = scratchDir
+	}
+
+	ext := filepath.Ext(outputPath)
+	stem := strings.TrimSuffix(filepath.Base(outputPath), ext)
+	return filepath.Join(dir, fmt.Sprintf(".%s.%s.partial%s", stem, taskID, ext))
+}
+
+// verifyOutput проверяет, что FFmpeg действительно записал полный файл
+func (s *ConverterService) verifyOutput(task *models.Task) error {
+	info, err := os.Stat(task.TempPath)
+	if err != nil {
+		return newTaskError(models.ErrorTypeVerification, "выходной файл не найден: %v", err)
+	}
+	if info.Size() == 0 {
+		return newTaskError(models.ErrorTypeVerification, "выходной файл пустой")
+	}
+
+	if task.Duration > 0 {
+		duration, err := probeDuration(task.TempPath)
+		if err != nil {
+			return newTaskError(models.ErrorTypeProbe, "не удалось проверить выходной файл: %v", err)
+		}
+
+		tolerance := math.Max(durationToleranceSeconds, task.Duration*durationTolerancePercent/100)
+		if math.Abs(duration-task.Duration) > tolerance {
+			return newTaskError(models.ErrorTypeVerification, "длительность результата %.1f сек не совпадает с исходной %.1f сек",
+				duration, task.Duration)
+		}
+	}
+
+	task.OutputSize = info.Size()
+	return nil
+}
+
+// moveIntoPlace переносит проверенный временный файл на итоговое имя.
+// Внутри одной файловой системы это атомарный rename, между файловыми
+// системами файл сначала копируется во временное имя рядом с итоговым
+func moveIntoPlace(tempPath, outputPath string) error {
+	err := os.Rename(tempPath, outputPath)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	staging := tempOutputPath("", outputPath, "move")
+	if err := copyFile(tempPath, staging); err != nil {
+		os.Remove(staging)
+		return err
+	}
+
+	if err := os.Rename(staging, outputPath); err != nil {
+		os.Remove(staging)
+		return err
+	}
+
+	return os.Remove(tempPath)
+}
+
+// copyFile копирует файл с принудительной записью на диск
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	// Сохраняем время изменения, чтобы медиасерверы не считали файл новым
+	if info, err := in.Stat(); err == nil {
+		os.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+// removeTempOutput удаляет временный файл задачи, если он остался
+func removeTempOutput(task *models.Task) {
+	if task.TempPath == "" {
+		return
+	}
+
+	if err := os.Remove(task.TempPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("Не удалось удалить временный файл %s: %v", task.TempPath, err)
+	}
+	task.TempPath = ""
+}
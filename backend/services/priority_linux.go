@@ -0,0 +1,70 @@
+//go:build linux
+
+package services
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"ultimate-dts-fix-server/backend/config"
+)
+
+// Константы ioprio_set из linux/ioprio.h
+const (
+	ioprioWhoProcess  = 1
+	ioprioClassShift  = 13
+	ioprioClassBE     = 2
+	ioprioClassIdle   = 3
+	priorityWhoThread = 0
+)
+
+// startWithPriority запускает команду из отдельного потока ОС с пониженным
+// приоритетом CPU и ввода-вывода. В Linux nice и ioprio задаются на поток
+// и наследуются дочерним процессом, поэтому FFmpeg и все его потоки
+// стартуют уже с нужным приоритетом
+func startWithPriority(cmd *exec.Cmd, cfg config.ThrottleConfig) error {
+	if cfg.Nice == 0 && cfg.IOClass == "" {
+		return cmd.Start()
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		// Поток не возвращается в пул: горутина завершается без UnlockOSThread,
+		// и Go уничтожает поток вместе с измененным приоритетом
+		runtime.LockOSThread()
+
+		if err := setThreadPriority(cfg); err != nil {
+			log.Printf("Предупреждение: не удалось понизить приоритет FFmpeg: %v", err)
+		}
+		errChan <- cmd.Start()
+	}()
+
+	return <-errChan
+}
+
+// setThreadPriority применяет nice и ioprio к текущему потоку
+func setThreadPriority(cfg config.ThrottleConfig) error {
+	if cfg.Nice != 0 {
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, priorityWhoThread, cfg.Nice); err != nil {
+			return fmt.Errorf("setpriority: %v", err)
+		}
+	}
+
+	var ioprio uintptr
+	switch cfg.IOClass {
+	case config.IOClassBestEffort:
+		ioprio = ioprioClassBE<<ioprioClassShift | uintptr(cfg.IOPriority)
+	case config.IOClassIdle:
+		ioprio = ioprioClassIdle << ioprioClassShift
+	default:
+		return nil
+	}
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, priorityWhoThread, ioprio); errno != 0 {
+		return fmt.Errorf("ioprio_set: %v", errno)
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package services
+
+import (
+	"log"
+	"os/exec"
+	"ultimate-dts-fix-server/backend/config"
+)
+
+// startWithPriority запускает команду без изменения приоритета,
+// понижение приоритета поддерживается только в Linux
+func startWithPriority(cmd *exec.Cmd, cfg config.ThrottleConfig) error {
+	if cfg.Nice != 0 || cfg.IOClass != "" {
+		log.Println("Предупреждение: понижение приоритета FFmpeg поддерживается только в Linux")
+	}
+	return cmd.Start()
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"ultimate-dts-fix-server/backend/database"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// ProbeCache переиспользует результаты ffprobe из описи медиатеки, пока
+// размер, время изменения и (по настройке) inode файла не изменились.
+// Методы nil-кэша вызывают ffprobe напрямую
+type ProbeCache struct {
+	store      *database.InventoryStore
+	checkInode bool
+}
+
+func NewProbeCache(store *database.InventoryStore, checkInode bool) *ProbeCache {
+	return &ProbeCache{
+		store:      store,
+		checkInode: checkInode,
+	}
+}
+
+// Probe возвращает аудиодорожки и длительность файла
+func (c *ProbeCache) Probe(ctx context.Context, path string) (*models.MediaFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, _ := c.ProbeFile(ctx, path, info, false)
+	if file.ProbeError != "" {
+		return nil, fmt.Errorf("ошибка выполнения ffprobe: %s", file.ProbeError)
+	}
+	return file, nil
+}
+
+// ProbeFile возвращает запись из кэша или заново читает файл ffprobe.
+// Второе значение - true, если ffprobe действительно запускался
+func (c *ProbeCache) ProbeFile(ctx context.Context, path string, info os.FileInfo, force bool) (*models.MediaFile, bool) {
+	if c == nil {
+		return probeMediaFile(ctx, path, info), true
+	}
+
+	if !force {
+		if cached := c.Cached(path, info); cached != nil {
+			return cached, false
+		}
+	}
+
+	file := probeMediaFile(ctx, path, info)
+	// Прерванный запуск ffprobe не означает, что файл испорчен
+	if ctx.Err() == nil {
+		c.store.Put(file)
+	}
+	return file, true
+}
+
+// Cached возвращает актуальную запись кэша без запуска ffprobe, nil - файл еще не читался или изменился
+func (c *ProbeCache) Cached(path string, info os.FileInfo) *models.MediaFile {
+	if c == nil {
+		return nil
+	}
+	if cached := c.store.Get(path); cached != nil && c.fresh(cached, info) {
+		return cached
+	}
+	return nil
+}
+
+// Invalidate удаляет записи о файлах, измененных конвертацией или откатом
+func (c *ProbeCache) Invalidate(paths ...string) {
+	if c == nil {
+		return
+	}
+
+	var nonEmpty []string
+	for _, path := range paths {
+		if path != "" {
+			nonEmpty = append(nonEmpty, path)
+		}
+	}
+	c.store.Delete(nonEmpty...)
+}
+
+// fresh проверяет, что запись соответствует файлу на диске.
+// После ошибки ffprobe файл читается заново
+func (c *ProbeCache) fresh(cached *models.MediaFile, info os.FileInfo) bool {
+	if cached.ProbeError != "" {
+		return false
+	}
+	if cached.Size != info.Size() || !cached.ModTime.Equal(info.ModTime()) {
+		return false
+	}
+	if c.checkInode && cached.Inode != 0 && cached.Inode != fileInode(info) {
+		return false
+	}
+	return true
+}
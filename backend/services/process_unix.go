@@ -0,0 +1,18 @@
+//go:build !windows
+
+package services
+
+import (
+	"os"
+	"syscall"
+)
+
+// suspendProcess приостанавливает процесс сигналом SIGSTOP
+func suspendProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGSTOP)
+}
+
+// resumeProcess продолжает приостановленный процесс сигналом SIGCONT
+func resumeProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGCONT)
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package services
+
+import (
+	"fmt"
+	"os"
+)
+
+// suspendProcess не поддерживается на Windows
+func suspendProcess(process *os.Process) error {
+	return fmt.Errorf("приостановка процесса не поддерживается на этой платформе")
+}
+
+// resumeProcess не поддерживается на Windows
+func resumeProcess(process *os.Process) error {
+	return fmt.Errorf("возобновление процесса не поддерживается на этой платформе")
+}
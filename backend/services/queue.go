@@ -2,33 +2,65 @@ package services
 
 import (
 	"log"
+	"path/filepath"
+	"sync"
 	"time"
+	"ultimate-dts-fix-server/backend/config"
 	"ultimate-dts-fix-server/backend/database"
 	"ultimate-dts-fix-server/backend/models"
 )
 
+// queuePausedKey - ключ флага паузы очереди в служебном состоянии
+const queuePausedKey = "queue_paused"
+
 type QueueService struct {
 	db        *database.TaskRepository
-	taskChan  chan *models.Task
 	stopChan  chan bool
 	wsService *WebSocketService
+	webhooks  *WebhookService
+	converter *ConverterService
+	paused    bool
+	mu        sync.RWMutex
+	// enqueueMu делает проверку дубликатов и создание задачи одной операцией
+	enqueueMu sync.Mutex
 }
 
 func NewQueueService(db *database.TaskRepository) *QueueService {
-	return &QueueService{
+	s := &QueueService{
 		db:       db,
-		taskChan: make(chan *models.Task, 100),
 		stopChan: make(chan bool),
 	}
+
+	// Восстанавливаем паузу очереди после перезапуска
+	if _, err := db.GetState(queuePausedKey, &s.paused); err != nil {
+		log.Printf("Ошибка чтения состояния очереди: %v", err)
+	}
+	if s.paused {
+		log.Println("Очередь приостановлена (состояние восстановлено)")
+	}
+
+	return s
 }
 
 func (s *QueueService) SetWebSocketService(wsService *WebSocketService) {
 	s.wsService = wsService
 }
 
+// SetWebhookService устанавливает сервис внешних webhooks
+func (s *QueueService) SetWebhookService(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+// SetConverterService нужен для поиска уже существующих результатов конвертации
+func (s *QueueService) SetConverterService(converter *ConverterService) {
+	s.converter = converter
+}
+
 func (s *QueueService) Start() {
 	log.Println("Сервис очереди запущен")
 
+	s.recoverInterruptedTasks()
+
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
@@ -36,8 +68,6 @@ func (s *QueueService) Start() {
 		select {
 		case <-ticker.C:
 			s.broadcastQueueUpdate()
-		case task := <-s.taskChan:
-			s.addTask(task)
 		case <-s.stopChan:
 			log.Println("Сервис очереди остановлен")
 			return
@@ -49,23 +79,108 @@ func (s *QueueService) Stop() {
 	s.stopChan <- true
 }
 
-func (s *QueueService) AddTask(task *models.Task) {
-	s.taskChan <- task
+// Enqueue добавляет задачу, если для того же файла нет незавершенной задачи.
+// Уже сконвертированный файл добавляется только с force, иначе возвращается *DuplicateError
+func (s *QueueService) Enqueue(task *models.Task, force bool) error {
+	s.enqueueMu.Lock()
+	defer s.enqueueMu.Unlock()
+
+	if err := s.checkDuplicate(task, force); err != nil {
+		return err
+	}
+	return s.addTask(task)
+}
+
+// recoverInterruptedTasks возвращает в ожидание задачи, чей процесс FFmpeg
+// был потерян при перезапуске сервера
+func (s *QueueService) recoverInterruptedTasks() {
+	tasks, err := s.db.GetPendingTasks()
+	if err != nil {
+		log.Printf("Ошибка получения задач для восстановления: %v", err)
+		return
+	}
+
+	for _, task := range tasks {
+		if task.Status != models.StatusProcessing && task.Status != models.StatusPaused {
+			continue
+		}
+
+		log.Printf("Задача %s прервана перезапуском, возвращаем в очередь", task.ID)
+		removeTempOutput(task)
+		resetToPending(task)
+		if err := s.db.UpdateTask(task); err != nil {
+			log.Printf("Ошибка восстановления задачи %s: %v", task.ID, err)
+		}
+	}
+}
+
+// resetToPending возвращает прерванную задачу в ожидание, прогресс начинается заново
+func resetToPending(task *models.Task) {
+	task.Status = models.StatusPending
+	task.Progress = 0
+	task.CurrentTime = 0
+	task.Elapsed = 0
+	task.PausedSeconds = 0
+	task.StartedAt = nil
+	task.PausedAt = nil
+}
+
+// PauseQueue останавливает запуск новых задач, текущая задача продолжает работу
+func (s *QueueService) PauseQueue() error {
+	return s.setPaused(true)
+}
+
+// ResumeQueue разрешает запуск новых задач
+func (s *QueueService) ResumeQueue() error {
+	return s.setPaused(false)
 }
 
-func (s *QueueService) addTask(task *models.Task) {
+// IsPaused возвращает true если очередь приостановлена
+func (s *QueueService) IsPaused() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.paused
+}
+
+func (s *QueueService) setPaused(paused bool) error {
+	s.mu.Lock()
+	if err := s.db.SetState(queuePausedKey, paused); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.paused = paused
+	s.mu.Unlock()
+
+	if paused {
+		log.Println("Очередь приостановлена")
+	} else {
+		log.Println("Очередь возобновлена")
+	}
+
+	if s.wsService != nil {
+		s.wsService.BroadcastQueueState(paused)
+	}
+	return nil
+}
+
+func (s *QueueService) addTask(task *models.Task) error {
 	if err := s.db.CreateTask(task); err != nil {
 		log.Printf("Ошибка добавления задачи в базу: %v", err)
-		return
+		return err
 	}
 
 	log.Printf("Задача добавлена в очередь: %s", task.FilePath)
 	s.broadcastQueueUpdate()
+
+	if s.webhooks != nil {
+		s.webhooks.Emit(config.EventTaskCreated, task)
+	}
+	return nil
 }
 
 func (s *QueueService) broadcastQueueUpdate() {
 	if s.wsService != nil {
-		tasks, err := s.db.GetAllTasks()
+		tasks, err := s.db.GetPendingTasks()
 		if err != nil {
 			log.Printf("Ошибка получения задач для broadcast: %v", err)
 			return
@@ -74,8 +189,39 @@ func (s *QueueService) broadcastQueueUpdate() {
 	}
 }
 
+// GetQueue возвращает незавершенные задачи в порядке очереди
 func (s *QueueService) GetQueue() ([]*models.Task, error) {
-	return s.db.GetAllTasks()
+	return s.db.GetPendingTasks()
+}
+
+// ListTasks возвращает страницу истории и очереди по фильтру
+func (s *QueueService) ListTasks(query database.TaskQuery) (*database.TaskPage, error) {
+	return s.db.ListTasks(query)
+}
+
+// FindActiveTask возвращает незавершенную задачу для файла, nil - такой задачи нет
+func (s *QueueService) FindActiveTask(filePath string) (*models.Task, error) {
+	tasks, err := s.db.GetPendingTasks()
+	if err != nil {
+		return nil, err
+	}
+
+	filePath = canonicalPath(filePath)
+	for _, task := range tasks {
+		if canonicalPath(task.FilePath) == filePath {
+			return task, nil
+		}
+	}
+	return nil, nil
+}
+
+// FindTasksByPath возвращает задачи, у которых исходный или выходной файл - filePath, новые первыми
+func (s *QueueService) FindTasksByPath(filePath string) []*models.Task {
+	tasks := s.db.FindTasksByPath(filePath)
+	if canonical := canonicalPath(filePath); canonical != filepath.Clean(filePath) {
+		tasks = append(tasks, s.db.FindTasksByPath(canonical)...)
+	}
+	return tasks
 }
 
 func (s *QueueService) GetTask(taskID string) (*models.Task, error) {
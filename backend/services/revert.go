@@ -0,0 +1,106 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// RevertTask откатывает завершенную конвертацию: возвращает исходный файл
+// на место и удаляет результат или переносит его в карантин
+func (s *ConverterService) RevertTask(taskID string, quarantineOutput bool) (*models.Task, error) {
+	task, err := s.queueService.GetTask(taskID)
+	if err != nil || task == nil {
+		return nil, fmt.Errorf("задача не найдена")
+	}
+	if task.Status != models.StatusCompleted {
+		return nil, fmt.Errorf("откатить можно только завершенную задачу")
+	}
+	if task.Finalize == config.FinalizeReplace {
+		return nil, fmt.Errorf("исходный файл был заменен результатом, откат невозможен")
+	}
+	if quarantineOutput && s.cfg.Quarantine.Dir == "" {
+		return nil, fmt.Errorf("карантин не настроен (quarantine.dir)")
+	}
+
+	backupPath := task.BackupPath
+	if backupPath == "" {
+		// Задачи до появления backupPath: пробуем стандартное имя .bak
+		backupPath = task.FilePath + ".bak"
+		log.Printf("Путь копии исходника не сохранен в задаче %s, пробуем %s", task.ID, backupPath)
+	}
+
+	if _, err := os.Stat(backupPath); err != nil {
+		return nil, fmt.Errorf("копия исходного файла не найдена: %s", backupPath)
+	}
+	if _, err := os.Stat(task.FilePath); err == nil {
+		return nil, fmt.Errorf("на месте исходного файла уже есть файл: %s", task.FilePath)
+	}
+
+	// Сначала возвращаем исходник, чтобы при ошибке не остаться без обоих файлов
+	if err := moveIntoPlace(backupPath, task.FilePath); err != nil {
+		return nil, fmt.Errorf("не удалось восстановить исходный файл: %v", err)
+	}
+	log.Printf("Исходный файл восстановлен: %s -> %s", backupPath, task.FilePath)
+
+	var warnings []string
+	if err := restoreSidecars(task); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	if err := s.discardOutput(task, quarantineOutput); err != nil {
+		log.Printf("ОШИБКА отката результата задачи %s: %v", task.ID, err)
+		warnings = append(warnings, err.Error())
+	}
+
+	s.probeCache.Invalidate(task.FilePath, task.OutputPath, task.BackupPath)
+
+	now := time.Now()
+	task.Status = models.StatusReverted
+	task.RevertedAt = &now
+	task.BackupPath = ""
+	if len(warnings) > 0 {
+		task.Error = "Откат выполнен с ошибками: " + warnings[0]
+	}
+
+	if err := s.queueService.UpdateTask(task); err != nil {
+		return task, fmt.Errorf("ошибка сохранения задачи: %v", err)
+	}
+	if s.wsService != nil {
+		s.wsService.BroadcastConversionProgress(task.ID, task.Progress, models.StatusReverted, "Конвертация откачена")
+	}
+	s.emitEvent(config.EventTaskReverted, task)
+	if s.notifier != nil {
+		s.notifier.NotifyTask(task)
+	}
+
+	return task, nil
+}
+
+// discardOutput удаляет результат конвертации или переносит его в карантин
+func (s *ConverterService) discardOutput(task *models.Task, quarantine bool) error {
+	if task.OutputPath == "" {
+		return nil
+	}
+	if _, err := os.Stat(task.OutputPath); os.IsNotExist(err) {
+		log.Printf("Результат уже отсутствует: %s", task.OutputPath)
+		return nil
+	}
+
+	if quarantine {
+		path, err := s.quarantineSource(task.OutputPath)
+		if err != nil {
+			return fmt.Errorf("не удалось перенести результат в карантин: %v", err)
+		}
+		log.Printf("Результат перенесен в карантин: %s", path)
+		return nil
+	}
+
+	if err := os.Remove(task.OutputPath); err != nil {
+		return fmt.Errorf("не удалось удалить результат: %v", err)
+	}
+	log.Printf("Результат удален: %s", task.OutputPath)
+	return nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+)
+
+// ScheduleState - состояние расписания для клиентов
+type ScheduleState struct {
+	Enabled         bool       `json:"enabled"`
+	Open            bool       `json:"open"`
+	OnWindowClose   string     `json:"onWindowClose"`
+	NextWindowStart *time.Time `json:"nextWindowStart,omitempty"`
+}
+
+// Scheduler определяет, разрешен ли запуск конвертаций в данный момент
+type Scheduler struct {
+	cfg config.ScheduleConfig
+}
+
+func NewScheduler(cfg config.ScheduleConfig) *Scheduler {
+	return &Scheduler{cfg: cfg}
+}
+
+// scheduleInterval - конкретный интервал окна обработки
+type scheduleInterval struct {
+	start time.Time
+	end   time.Time
+}
+
+// IsOpen возвращает true если now попадает в одно из окон.
+// Выключенное расписание или пустой список окон не ограничивают обработку
+func (s *Scheduler) IsOpen(now time.Time) bool {
+	if !s.cfg.Enabled || len(s.cfg.Windows) == 0 {
+		return true
+	}
+
+	// Окно, начавшееся вчера, может переходить через полночь
+	for _, interval := range s.intervals(now, -1, 0) {
+		if !now.Before(interval.start) && now.Before(interval.end) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NextOpen возвращает начало ближайшего окна, если сейчас обработка запрещена
+func (s *Scheduler) NextOpen(now time.Time) *time.Time {
+	if s.IsOpen(now) {
+		return nil
+	}
+
+	var next *time.Time
+	for _, interval := range s.intervals(now, 0, 7) {
+		if interval.start.After(now) && (next == nil || interval.start.Before(*next)) {
+			start := interval.start
+			next = &start
+		}
+	}
+
+	return next
+}
+
+// OnWindowClose возвращает политику для задачи, выполняющейся при закрытии окна
+func (s *Scheduler) OnWindowClose() string {
+	return s.cfg.OnWindowClose
+}
+
+// State возвращает состояние расписания на момент now
+func (s *Scheduler) State(now time.Time) ScheduleState {
+	return ScheduleState{
+		Enabled:         s.cfg.Enabled,
+		Open:            s.IsOpen(now),
+		OnWindowClose:   s.cfg.OnWindowClose,
+		NextWindowStart: s.NextOpen(now),
+	}
+}
+
+// intervals разворачивает окна в конкретные интервалы для дней
+// со смещением от fromDay до toDay включительно относительно now
+func (s *Scheduler) intervals(now time.Time, fromDay, toDay int) []scheduleInterval {
+	var result []scheduleInterval
+
+	for _, window := range s.cfg.Windows {
+		// Конфигурация проверена при загрузке
+		days, _ := window.Weekdays()
+		startMin, endMin, _ := window.Bounds()
+		if endMin <= startMin {
+			endMin += 24 * 60
+		}
+
+		for offset := fromDay; offset <= toDay; offset++ {
+			day := time.Date(now.Year(), now.Month(), now.Day()+offset, 0, 0, 0, 0, now.Location())
+			if !days[day.Weekday()] {
+				continue
+			}
+			result = append(result, scheduleInterval{
+				start: day.Add(time.Duration(startMin) * time.Minute),
+				end:   day.Add(time.Duration(endMin) * time.Minute),
+			})
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// searchBatchSize - сколько найденных файлов отправляется одним сообщением
+	searchBatchSize = 50
+	// searchFlushInterval - как часто отправляется прогресс, даже если ничего не найдено
+	searchFlushInterval = 500 * time.Millisecond
+)
+
+// searchJob - фоновый поиск файлов, результаты получает только запустивший его клиент
+type searchJob struct {
+	id      string
+	conn    *websocket.Conn
+	pattern string
+	cancel  context.CancelFunc
+}
+
+// SearchProgress - очередная порция результатов поиска и счетчики обхода
+type SearchProgress struct {
+	SearchID     string                   `json:"searchId"`
+	Files        []map[string]interface{} `json:"files"`
+	Found        int                      `json:"found"`
+	ScannedDirs  int                      `json:"scannedDirs"`
+	ScannedFiles int                      `json:"scannedFiles"`
+	CurrentDir   string                   `json:"currentDir,omitempty"`
+	Completed    bool                     `json:"completed"`
+	Cancelled    bool                     `json:"cancelled,omitempty"`
+	// Truncated - поиск остановлен по достижении maxResults
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// startSearch запускает поиск в фоне. Предыдущий поиск того же клиента отменяется
+func (s *WebSocketService) startSearch(conn *websocket.Conn, pattern string, maxResults int) *searchJob {
+	s.cancelSearches(conn, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.searchesMux.Lock()
+	s.searchSeq++
+	job := &searchJob{
+		id:      strconv.FormatUint(s.searchSeq, 10),
+		conn:    conn,
+		pattern: pattern,
+		cancel:  cancel,
+	}
+	s.searches[job.id] = job
+	s.searchesMux.Unlock()
+
+	go s.runSearch(ctx, job, newFileNameMatcher(pattern), maxResults)
+	return job
+}
+
+// cancelSearches отменяет поиск клиента по идентификатору, пустой id - все поиски клиента.
+// Возвращает число отмененных поисков
+func (s *WebSocketService) cancelSearches(conn *websocket.Conn, id string) int {
+	s.searchesMux.Lock()
+	defer s.searchesMux.Unlock()
+
+	cancelled := 0
+	for jobID, job := range s.searches {
+		if job.conn != conn || (id != "" && jobID != id) {
+			continue
+		}
+		job.cancel()
+		delete(s.searches, jobID)
+		cancelled++
+	}
+	return cancelled
+}
+
+// runSearch обходит медиатеки и отправляет клиенту найденные файлы порциями
+func (s *WebSocketService) runSearch(ctx context.Context, job *searchJob, match func(string) bool, maxResults int) {
+	defer func() {
+		s.searchesMux.Lock()
+		if s.searches[job.id] == job {
+			delete(s.searches, job.id)
+		}
+		s.searchesMux.Unlock()
+		job.cancel()
+	}()
+
+	progress := SearchProgress{SearchID: job.id, Files: []map[string]interface{}{}}
+	lastFlush := time.Now()
+
+	// flush отправляет накопленное, ошибка записи означает, что клиент отключился
+	flush := func() bool {
+		if err := s.sendTo(job.conn, "search_progress", progress); err != nil {
+			job.cancel()
+			return false
+		}
+		progress.Files = []map[string]interface{}{}
+		lastFlush = time.Now()
+		return true
+	}
+
+	errStop := fmt.Errorf("остановлено")
+	for _, root := range s.searchRoots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return errStop
+			}
+			if err != nil {
+				// Недоступные директории пропускаем, как и раньше
+				if d != nil && d.IsDir() && path != root {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if d.IsDir() {
+				if path != root && s.searchConfig.Excluded(path) {
+					return filepath.SkipDir
+				}
+				progress.ScannedDirs++
+				progress.CurrentDir = path
+			} else {
+				progress.ScannedFiles++
+				if isVideoFile(path) && match(d.Name()) {
+					if info, err := d.Info(); err == nil {
+						progress.Files = append(progress.Files, map[string]interface{}{
+							"path":     path,
+							"name":     d.Name(),
+							"size":     info.Size(),
+							"modified": info.ModTime().Unix(),
+						})
+						progress.Found++
+					}
+				}
+			}
+
+			if maxResults > 0 && progress.Found >= maxResults {
+				progress.Truncated = true
+				return errStop
+			}
+			if len(progress.Files) >= searchBatchSize || time.Since(lastFlush) >= searchFlushInterval {
+				if !flush() {
+					return errStop
+				}
+			}
+			return nil
+		})
+
+		if err != nil && err != errStop {
+			log.Printf("Ошибка поиска в %s: %v", root, err)
+			progress.Error = err.Error()
+		}
+		if ctx.Err() != nil || progress.Truncated {
+			break
+		}
+	}
+
+	progress.Completed = true
+	progress.Cancelled = ctx.Err() != nil && !progress.Truncated
+	progress.CurrentDir = ""
+	log.Printf("Поиск %q завершен: найдено %d, просмотрено файлов %d", job.pattern, progress.Found, progress.ScannedFiles)
+	flush()
+}
+
+// newFileNameMatcher возвращает проверку имени файла: регулярное выражение без учета
+// регистра или, если шаблон не компилируется, поиск подстроки
+func newFileNameMatcher(pattern string) func(string) bool {
+	if re, err := regexp.Compile("(?i)" + pattern); err == nil {
+		return re.MatchString
+	}
+
+	lower := strings.ToLower(pattern)
+	return func(name string) bool {
+		return strings.Contains(strings.ToLower(name), lower)
+	}
+}
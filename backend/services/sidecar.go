@@ -0,0 +1,159 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+var (
+	// sidecarLangRe - код языка в имени субтитров: en, rus, pt-BR
+	sidecarLangRe = regexp.MustCompile(`^[A-Za-z]{2,3}(?:[-_][A-Za-z0-9]{2,4})?$`)
+	// sidecarFlags - пометки дорожки субтитров после кода языка
+	sidecarFlags = map[string]bool{"forced": true, "sdh": true, "cc": true, "hi": true, "default": true}
+	// sidecarArtwork - обложки в формате Kodi: Movie-poster.jpg, Movie-fanart.jpg
+	sidecarArtwork = map[string]bool{
+		"poster": true, "fanart": true, "banner": true, "thumb": true, "landscape": true,
+		"clearlogo": true, "clearart": true, "discart": true, "logo": true, "disc": true,
+	}
+)
+
+// findSidecars ищет рядом с исходником файлы с тем же именем и разрешенным расширением,
+// например Movie.en.srt, Movie.nfo или Movie-poster.jpg для Movie.mkv
+func findSidecars(inputPath string, extensions []string) ([]string, error) {
+	dir := filepath.Dir(inputPath)
+	stem := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+
+	allowed := make(map[string]bool)
+	for _, ext := range extensions {
+		allowed["."+strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var sidecars []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, stem) {
+			continue
+		}
+		if isSidecarSuffix(name[len(stem):], allowed) {
+			sidecars = append(sidecars, filepath.Join(dir, name))
+		}
+	}
+
+	return sidecars, nil
+}
+
+// isSidecarSuffix проверяет остаток имени после имени исходника: [.язык][.forced].расширение
+// или -обложка.расширение. Так Movie.2.en.srt и Movie2.srt не считаются файлами Movie.mkv
+func isSidecarSuffix(suffix string, allowed map[string]bool) bool {
+	ext := filepath.Ext(suffix)
+	if !allowed[strings.ToLower(ext)] {
+		return false
+	}
+	rest := strings.TrimSuffix(suffix, ext)
+	if rest == "" {
+		return true
+	}
+
+	if strings.HasPrefix(rest, "-") {
+		return sidecarArtwork[strings.ToLower(rest[1:])]
+	}
+	if !strings.HasPrefix(rest, ".") {
+		return false
+	}
+
+	parts := strings.Split(rest[1:], ".")
+	if len(parts) > 2 {
+		return false
+	}
+	for i, part := range parts {
+		switch {
+		case sidecarFlags[strings.ToLower(part)] && i == len(parts)-1:
+		case sidecarLangRe.MatchString(part) && i == 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// moveSidecars переименовывает или копирует сопутствующие файлы под имя результата
+func (s *ConverterService) moveSidecars(task *models.Task) {
+	cfg := s.cfg.Sidecars
+	if cfg.Mode == config.SidecarOff || task.OutputPath == "" {
+		return
+	}
+
+	sourceStem := strings.TrimSuffix(filepath.Base(task.FilePath), filepath.Ext(task.FilePath))
+	outputStem := strings.TrimSuffix(filepath.Base(task.OutputPath), filepath.Ext(task.OutputPath))
+	if sourceStem == outputStem && filepath.Dir(task.FilePath) == filepath.Dir(task.OutputPath) {
+		return
+	}
+
+	sidecars, err := findSidecars(task.FilePath, cfg.Extensions)
+	if err != nil {
+		log.Printf("Ошибка поиска сопутствующих файлов: %v", err)
+		return
+	}
+
+	for _, source := range sidecars {
+		suffix := strings.TrimPrefix(filepath.Base(source), sourceStem)
+		target := filepath.Join(filepath.Dir(task.OutputPath), outputStem+suffix)
+
+		if _, err := os.Stat(target); err == nil {
+			log.Printf("Сопутствующий файл пропущен, %s уже существует", target)
+			continue
+		}
+
+		copied := cfg.Mode == config.SidecarCopy
+		if copied {
+			err = copyFile(source, target)
+		} else {
+			err = os.Rename(source, target)
+		}
+		if err != nil {
+			log.Printf("Ошибка переноса сопутствующего файла %s: %v", source, err)
+			continue
+		}
+
+		log.Printf("Сопутствующий файл: %s -> %s", source, target)
+		task.Sidecars = append(task.Sidecars, models.SidecarFile{Source: source, Target: target, Copied: copied})
+	}
+}
+
+// restoreSidecars отменяет moveSidecars при откате задачи
+func restoreSidecars(task *models.Task) error {
+	var failed []string
+
+	for _, sidecar := range task.Sidecars {
+		var err error
+		if sidecar.Copied {
+			err = os.Remove(sidecar.Target)
+		} else if _, statErr := os.Stat(sidecar.Source); statErr == nil {
+			err = fmt.Errorf("файл %s уже существует", sidecar.Source)
+		} else {
+			err = os.Rename(sidecar.Target, sidecar.Source)
+		}
+
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Ошибка восстановления сопутствующего файла %s: %v", sidecar.Source, err)
+			failed = append(failed, filepath.Base(sidecar.Source))
+		}
+	}
+
+	task.Sidecars = nil
+	if len(failed) > 0 {
+		return fmt.Errorf("не удалось восстановить сопутствующие файлы: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestFindSidecars(t *testing.T) {
+	extensions := []string{"srt", "ass", "sub", "idx", "nfo", "jpg"}
+
+	tests := []struct {
+		name  string
+		files []string
+		want  []string
+	}{
+		{
+			name:  "subtitles, nfo and artwork",
+			files: []string{"Movie.srt", "Movie.en.srt", "Movie.en.forced.srt", "Movie.pt-BR.ass", "Movie.nfo", "Movie-poster.jpg"},
+			want:  []string{"Movie-poster.jpg", "Movie.en.forced.srt", "Movie.en.srt", "Movie.nfo", "Movie.pt-BR.ass", "Movie.srt"},
+		},
+		{
+			name:  "forced without language",
+			files: []string{"Movie.forced.srt"},
+			want:  []string{"Movie.forced.srt"},
+		},
+		{
+			// Movie.2.mkv лежит рядом, его субтитры не относятся к Movie.mkv
+			name:  "sibling with a longer name",
+			files: []string{"Movie.2.mkv", "Movie.2.en.srt", "Movie.2.srt", "Movie.2-poster.jpg"},
+		},
+		{
+			name:  "name without separator",
+			files: []string{"Movie2.srt", "Movies.nfo"},
+		},
+		{
+			name:  "other words after the name",
+			files: []string{"Movie.Extended.srt", "Movie.en.Extended.srt", "Movie.forced.en.srt", "Movie-trailer.jpg"},
+		},
+		{
+			name:  "extension not in the list",
+			files: []string{"Movie.en.txt", "Movie.mkv.part"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			source := filepath.Join(dir, "Movie.mkv")
+			writeTestFile(t, source, 10)
+			for _, name := range tt.files {
+				writeTestFile(t, filepath.Join(dir, name), 10)
+			}
+			// Директория с подходящим именем не считается сопутствующим файлом
+			if err := os.Mkdir(filepath.Join(dir, "Movie.fr.srt"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			found, err := findSidecars(source, extensions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, path := range found {
+				got = append(got, filepath.Base(path))
+			}
+			sort.Strings(got)
+			if !equalNames(got, tt.want) {
+				t.Errorf("findSidecars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"ultimate-dts-fix-server/backend/database"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+const (
+	// DefaultStatsDays и MaxStatsDays - сколько дней показывать в разбивке по дням
+	DefaultStatsDays = 30
+	MaxStatsDays     = 365
+)
+
+// statsWindows - скользящие окна статистики, нулевая длина - вся история
+var statsWindows = []struct {
+	name string
+	span time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+	{"all", 0},
+}
+
+// WindowStats - итоги за окно. Объем, время и скорость считаются по успешно завершенным задачам
+type WindowStats struct {
+	Window    string     `json:"window"`
+	Since     *time.Time `json:"since,omitempty"`
+	Completed int        `json:"completed"`
+	Failed    int        `json:"failed"`
+	Skipped   int        `json:"skipped"`
+	Reverted  int        `json:"reverted"`
+	// FailureRate - доля ошибок среди запущенных задач от 0 до 1. Пропущенные
+	// и отмененные пользователем задачи не учитываются
+	FailureRate float64 `json:"failureRate"`
+	// AudioHours - длительность сконвертированного видео
+	AudioHours float64 `json:"audioHours"`
+	// ProcessingHours - время работы FFmpeg без учета пауз
+	ProcessingHours float64 `json:"processingHours"`
+	// AverageSpeed - во сколько раз конвертация быстрее реального времени
+	AverageSpeed float64          `json:"averageSpeed"`
+	SourceBytes  int64            `json:"sourceBytes"`
+	OutputBytes  int64            `json:"outputBytes"`
+	Errors       []ErrorTypeStats `json:"errors"`
+}
+
+// ErrorTypeStats - число ошибок одного типа
+type ErrorTypeStats struct {
+	Type  string `json:"type"`
+	Count int    `json:"count"`
+}
+
+// DayStats - итоги за календарный день по времени сервера
+type DayStats struct {
+	Date        string  `json:"date"`
+	Completed   int     `json:"completed"`
+	Failed      int     `json:"failed"`
+	AudioHours  float64 `json:"audioHours"`
+	SourceBytes int64   `json:"sourceBytes"`
+	OutputBytes int64   `json:"outputBytes"`
+}
+
+// StatsReport - статистика по истории и архиву
+type StatsReport struct {
+	GeneratedAt time.Time     `json:"generatedAt"`
+	Windows     []WindowStats `json:"windows"`
+	// Days - последние дни, начиная с самого раннего, дни без задач тоже есть
+	Days []DayStats `json:"days"`
+}
+
+// statsRecord - то, что нужно статистике от завершенной задачи
+type statsRecord struct {
+	at         time.Time
+	status     models.TaskStatus
+	errorType  string
+	duration   float64
+	elapsed    float64
+	sourceSize int64
+	outputSize int64
+}
+
+// StatsService считает статистику конвертаций по истории задач и архиву
+type StatsService struct {
+	db *database.TaskRepository
+
+	// Архив только дописывается, поэтому его записи читаются заново лишь при изменении файла
+	mu          sync.Mutex
+	archived    []statsRecord
+	archiveSize int64
+	archiveMod  time.Time
+}
+
+func NewStatsService(db *database.TaskRepository) *StatsService {
+	return &StatsService{db: db}
+}
+
+// Report считает итоги по окнам и по дням за последние days дней
+func (s *StatsService) Report(days int) (*StatsReport, error) {
+	if days <= 0 {
+		days = DefaultStatsDays
+	}
+	if days > MaxStatsDays {
+		days = MaxStatsDays
+	}
+
+	tasks, err := s.db.GetAllTasks()
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.archivedRecords()
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		if !task.IsActive() {
+			records = append(records, newStatsRecord(task))
+		}
+	}
+
+	now := time.Now()
+	report := &StatsReport{GeneratedAt: now}
+	for _, window := range statsWindows {
+		var since time.Time
+		if window.span > 0 {
+			since = now.Add(-window.span)
+		}
+		report.Windows = append(report.Windows, windowStats(window.name, since, records))
+	}
+	report.Days = dayStats(records, now, days)
+	return report, nil
+}
+
+// archivedRecords возвращает записи архива, перечитывая его только после изменений
+func (s *StatsService) archivedRecords() ([]statsRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.db.Archive().Path())
+	if os.IsNotExist(err) {
+		s.archived, s.archiveSize, s.archiveMod = nil, 0, time.Time{}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() != s.archiveSize || !info.ModTime().Equal(s.archiveMod) {
+		var records []statsRecord
+		err := s.db.Archive().Each(func(task *models.Task) error {
+			records = append(records, newStatsRecord(task))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		s.archived, s.archiveSize, s.archiveMod = records, info.Size(), info.ModTime()
+	}
+
+	// Копия, чтобы вызывающий мог дописывать свои записи
+	return append([]statsRecord(nil), s.archived...), nil
+}
+
+func newStatsRecord(task *models.Task) statsRecord {
+	record := statsRecord{
+		at:         task.ActivityAt(),
+		status:     task.Status,
+		duration:   task.Duration,
+		elapsed:    task.Elapsed,
+		sourceSize: task.SourceSize,
+		outputSize: task.OutputSize,
+	}
+	if task.Status == models.StatusError {
+		// У задач из прежних версий тип ошибки не сохранен
+		record.errorType = task.ErrorType
+		if record.errorType == "" {
+			record.errorType = models.ErrorTypeOther
+		}
+	}
+	return record
+}
+
+// windowStats считает итоги по записям, завершенным не раньше since
+func windowStats(name string, since time.Time, records []statsRecord) WindowStats {
+	stats := WindowStats{Window: name, Errors: []ErrorTypeStats{}}
+	if !since.IsZero() {
+		stats.Since = &since
+	}
+
+	errorCounts := make(map[string]int)
+	var audio, processing, timedAudio, timedProcessing float64
+	for _, record := range records {
+		if record.at.Before(since) {
+			continue
+		}
+
+		switch record.status {
+		case models.StatusCompleted:
+			stats.Completed++
+			stats.SourceBytes += record.sourceSize
+			stats.OutputBytes += record.outputSize
+			audio += record.duration
+			processing += record.elapsed
+			// Скорость - только по задачам, у которых известны оба значения
+			if record.duration > 0 && record.elapsed > 0 {
+				timedAudio += record.duration
+				timedProcessing += record.elapsed
+			}
+		case models.StatusError:
+			stats.Failed++
+			errorCounts[record.errorType]++
+		case models.StatusSkipped:
+			stats.Skipped++
+		case models.StatusReverted:
+			stats.Reverted++
+		}
+	}
+
+	cancelled := errorCounts[models.ErrorTypeCancelled]
+	if started := stats.Completed + stats.Reverted + stats.Failed - cancelled; started > 0 {
+		stats.FailureRate = float64(stats.Failed-cancelled) / float64(started)
+	}
+	stats.AudioHours = audio / 3600
+	stats.ProcessingHours = processing / 3600
+	if timedProcessing > 0 {
+		stats.AverageSpeed = timedAudio / timedProcessing
+	}
+
+	for errorType, count := range errorCounts {
+		stats.Errors = append(stats.Errors, ErrorTypeStats{Type: errorType, Count: count})
+	}
+	sort.Slice(stats.Errors, func(i, j int) bool {
+		if stats.Errors[i].Count != stats.Errors[j].Count {
+			return stats.Errors[i].Count > stats.Errors[j].Count
+		}
+		return stats.Errors[i].Type < stats.Errors[j].Type
+	})
+	return stats
+}
+
+// dayStats раскладывает завершенные и ошибочные задачи последних days дней по датам
+func dayStats(records []statsRecord, now time.Time, days int) []DayStats {
+	result := make([]DayStats, days)
+	index := make(map[string]int, days)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	for i := 0; i < days; i++ {
+		date := today.AddDate(0, 0, i-days+1).Format("2006-01-02")
+		result[i].Date = date
+		index[date] = i
+	}
+
+	for _, record := range records {
+		i, exists := index[record.at.In(now.Location()).Format("2006-01-02")]
+		if !exists {
+			continue
+		}
+		switch record.status {
+		case models.StatusCompleted:
+			result[i].Completed++
+			result[i].AudioHours += record.duration / 3600
+			result[i].SourceBytes += record.sourceSize
+			result[i].OutputBytes += record.outputSize
+		case models.StatusError:
+			result[i].Failed++
+		}
+	}
+	return result
+}
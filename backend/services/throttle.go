@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"ultimate-dts-fix-server/backend/config"
+)
+
+// loadMonitor читает загрузку системы из /proc и решает, можно ли запускать новую задачу
+type loadMonitor struct {
+	cfg       config.ThrottleConfig
+	prevTotal uint64
+	prevWait  uint64
+}
+
+func newLoadMonitor(cfg config.ThrottleConfig) *loadMonitor {
+	return &loadMonitor{cfg: cfg}
+}
+
+// overloaded возвращает причину, по которой запуск новой задачи стоит отложить,
+// или пустую строку. Если /proc недоступен, ограничение не применяется
+func (m *loadMonitor) overloaded() string {
+	if m.cfg.MaxLoadAverage > 0 {
+		if load, err := readLoadAverage(); err == nil && load > m.cfg.MaxLoadAverage {
+			return fmt.Sprintf("load average %.2f выше порога %.2f", load, m.cfg.MaxLoadAverage)
+		}
+	}
+
+	if m.cfg.MaxIOWait > 0 {
+		if ioWait, ok := m.sampleIOWait(); ok && ioWait > m.cfg.MaxIOWait {
+			return fmt.Sprintf("iowait %.1f%% выше порога %.1f%%", ioWait, m.cfg.MaxIOWait)
+		}
+	}
+
+	return ""
+}
+
+// sampleIOWait вычисляет долю iowait с момента предыдущего вызова
+func (m *loadMonitor) sampleIOWait() (float64, bool) {
+	total, wait, err := readCPUTimes()
+	if err != nil {
+		return 0, false
+	}
+
+	prevTotal, prevWait := m.prevTotal, m.prevWait
+	m.prevTotal, m.prevWait = total, wait
+
+	if prevTotal == 0 || total <= prevTotal {
+		return 0, false
+	}
+
+	return float64(wait-prevWait) / float64(total-prevTotal) * 100, true
+}
+
+// readLoadAverage возвращает load average за 1 минуту из /proc/loadavg
+func readLoadAverage() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("пустой /proc/loadavg")
+	}
+
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// readCPUTimes возвращает суммарное время CPU и время iowait из строки cpu в /proc/stat
+func readCPUTimes() (uint64, uint64, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || fields[0] != "cpu" {
+			continue
+		}
+
+		// user nice system idle iowait irq softirq steal ...
+		var total uint64
+		for _, field := range fields[1:] {
+			value, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, 0, err
+			}
+			total += value
+		}
+
+		wait, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		return total, wait, nil
+	}
+
+	return 0, 0, fmt.Errorf("строка cpu не найдена в /proc/stat")
+}
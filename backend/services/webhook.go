@@ -0,0 +1,250 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/database"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+const (
+	// webhookDeliveriesKey - ключ журнала доставок в служебном состоянии
+	webhookDeliveriesKey = "webhook_deliveries"
+	// webhookDeliveryLogSize - сколько последних доставок хранится в журнале
+	webhookDeliveryLogSize = 200
+)
+
+// WebhookPayload - тело запроса, отправляемого в webhook
+type WebhookPayload struct {
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	// Milestone - пройденный порог прогресса для события task.progress
+	Milestone int         `json:"milestone,omitempty"`
+	Task      models.Task `json:"task"`
+}
+
+// WebhookDelivery - запись журнала доставки события
+type WebhookDelivery struct {
+	ID         string     `json:"id"`
+	Webhook    string     `json:"webhook"`
+	Event      string     `json:"event"`
+	TaskID     string     `json:"taskId"`
+	Status     string     `json:"status"`
+	Attempts   int        `json:"attempts"`
+	StatusCode int        `json:"statusCode,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// WebhookService отправляет события жизненного цикла задач во внешние webhooks
+type WebhookService struct {
+	webhooks   []config.WebhookConfig
+	db         *database.TaskRepository
+	deliveries []WebhookDelivery
+	mu         sync.Mutex
+}
+
+func NewWebhookService(webhooks []config.WebhookConfig, db *database.TaskRepository) *WebhookService {
+	s := &WebhookService{
+		webhooks: webhooks,
+		db:       db,
+	}
+
+	if _, err := db.GetState(webhookDeliveriesKey, &s.deliveries); err != nil {
+		log.Printf("Ошибка чтения журнала webhooks: %v", err)
+	}
+
+	return s
+}
+
+// Emit отправляет событие всем webhooks, подписанным на него
+func (s *WebhookService) Emit(event string, task *models.Task) {
+	for _, webhook := range s.webhooks {
+		if webhook.Accepts(event) {
+			s.send(webhook, event, 0, task)
+		}
+	}
+}
+
+// EmitProgress отправляет task.progress, если прогресс прошел порог webhook.
+// За один вызов отправляется только наибольший пройденный порог
+func (s *WebhookService) EmitProgress(task *models.Task, previous, current int) {
+	for _, webhook := range s.webhooks {
+		if !webhook.Accepts(config.EventTaskProgress) {
+			continue
+		}
+
+		milestone := 0
+		for _, m := range webhook.ProgressMilestones {
+			if previous < m && m <= current && m > milestone {
+				milestone = m
+			}
+		}
+		if milestone > 0 {
+			s.send(webhook, config.EventTaskProgress, milestone, task)
+		}
+	}
+}
+
+// GetDeliveries возвращает журнал доставок, новые записи первыми
+func (s *WebhookService) GetDeliveries(limit int) []WebhookDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if limit <= 0 || limit > len(s.deliveries) {
+		limit = len(s.deliveries)
+	}
+
+	result := make([]WebhookDelivery, 0, limit)
+	for i := len(s.deliveries) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, s.deliveries[i])
+	}
+	return result
+}
+
+// send сериализует задачу в момент события и доставляет ее асинхронно
+func (s *WebhookService) send(webhook config.WebhookConfig, event string, milestone int, task *models.Task) {
+	body, err := json.Marshal(WebhookPayload{
+		Event:     event,
+		Timestamp: time.Now(),
+		Milestone: milestone,
+		Task:      *task,
+	})
+	if err != nil {
+		log.Printf("Ошибка сериализации события %s: %v", event, err)
+		return
+	}
+
+	delivery := WebhookDelivery{
+		ID:        newDeliveryID(),
+		Webhook:   webhook.Name,
+		Event:     event,
+		TaskID:    task.ID,
+		Status:    NotificationPending,
+		CreatedAt: time.Now(),
+	}
+
+	go func() {
+		statusCode, attempts, err := s.deliver(webhook, &delivery, body)
+
+		now := time.Now()
+		delivery.Attempts = attempts
+		delivery.StatusCode = statusCode
+		delivery.FinishedAt = &now
+		if err != nil {
+			delivery.Status = NotificationFailed
+			delivery.Error = err.Error()
+			log.Printf("Webhook %s: событие %s задачи %s не доставлено: %v", webhook.Name, event, task.ID, err)
+		} else {
+			delivery.Status = NotificationOK
+		}
+
+		s.record(delivery)
+	}()
+}
+
+// record добавляет доставку в журнал и сохраняет его
+func (s *WebhookService) record(delivery WebhookDelivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries = append(s.deliveries, delivery)
+	if len(s.deliveries) > webhookDeliveryLogSize {
+		s.deliveries = s.deliveries[len(s.deliveries)-webhookDeliveryLogSize:]
+	}
+
+	if err := s.db.SetState(webhookDeliveriesKey, s.deliveries); err != nil {
+		log.Printf("Ошибка сохранения журнала webhooks: %v", err)
+	}
+}
+
+// deliver отправляет событие с повторами и возвращает код последнего ответа и число попыток
+func (s *WebhookService) deliver(webhook config.WebhookConfig, delivery *WebhookDelivery, body []byte) (int, int, error) {
+	client := &http.Client{Timeout: time.Duration(webhook.TimeoutSeconds * float64(time.Second))}
+	delay := time.Duration(webhook.RetryDelaySeconds * float64(time.Second))
+
+	var err error
+	statusCode := 0
+	attempts := 0
+	for attempt := 0; attempt <= *webhook.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		attempts++
+
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+		if err != nil {
+			return 0, attempts, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "ultimate-dts-fix-server")
+		req.Header.Set("X-Webhook-Event", delivery.Event)
+		req.Header.Set("X-Webhook-Delivery", delivery.ID)
+		if webhook.Secret != "" {
+			req.Header.Set("X-Webhook-Signature-256", "sha256="+signPayload(webhook.Secret, body))
+		}
+
+		statusCode, err = doWebhookRequest(client, req)
+		if err == nil {
+			return statusCode, attempts, nil
+		}
+		// Ошибки клиента, кроме таймаута и ограничения частоты, повтором не исправить
+		if statusCode >= 400 && statusCode < 500 &&
+			statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests {
+			break
+		}
+	}
+
+	return statusCode, attempts, err
+}
+
+// doWebhookRequest выполняет запрос и считает ошибкой любой ответ кроме 2xx
+func doWebhookRequest(client *http.Client, req *http.Request) (int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			return 0, urlErr.Err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return resp.StatusCode, nil
+}
+
+// signPayload возвращает HMAC-SHA256 тела запроса в hex
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newDeliveryID возвращает случайный идентификатор доставки
+func newDeliveryID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
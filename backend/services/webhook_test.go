@@ -0,0 +1,262 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/database"
+	"ultimate-dts-fix-server/backend/models"
+)
+
+// newTestDB открывает хранилище во временной директории
+func newTestDB(t *testing.T, dir string) *database.TaskRepository {
+	t.Helper()
+	db, err := database.OpenDB(filepath.Join(dir, "tasks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// webhookRequest - событие, полученное тестовым обработчиком
+type webhookRequest struct {
+	header  http.Header
+	body    []byte
+	payload WebhookPayload
+}
+
+// webhookServer отвечает 500 на путь /fail и 200 на остальные
+type webhookServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []webhookRequest
+}
+
+func newWebhookServer(t *testing.T) *webhookServer {
+	t.Helper()
+	s := &webhookServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload WebhookPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("payload %s: %v", body, err)
+		}
+
+		s.mu.Lock()
+		s.requests = append(s.requests, webhookRequest{r.Header.Clone(), body, payload})
+		s.mu.Unlock()
+
+		if r.URL.Path == "/fail" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *webhookServer) received() []webhookRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]webhookRequest(nil), s.requests...)
+}
+
+// testWebhook возвращает настройки без задержки между повторами
+func testWebhook(name, webhookURL string, retries int) config.WebhookConfig {
+	return config.WebhookConfig{
+		Name:               name,
+		URL:                webhookURL,
+		ProgressMilestones: []int{25, 50, 75},
+		Retries:            &retries,
+		RetryDelaySeconds:  0.001,
+		TimeoutSeconds:     5,
+	}
+}
+
+// waitDeliveries ждет, пока в журнале окажется count доставок
+func waitDeliveries(t *testing.T, service *WebhookService, count int) []WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries := service.GetDeliveries(0)
+		if len(deliveries) >= count {
+			return deliveries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d deliveries, want %d", len(deliveries), count)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	// Известный пример HMAC-SHA256 из Википедии
+	got := signPayload("key", []byte("The quick brown fox jumps over the lazy dog"))
+	if want := "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"; got != want {
+		t.Errorf("signPayload() = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookSignatureHeader(t *testing.T) {
+	server := newWebhookServer(t)
+	webhook := testWebhook("signed", server.URL, 0)
+	webhook.Secret = "s3cret"
+	service := NewWebhookService([]config.WebhookConfig{webhook}, newTestDB(t, t.TempDir()))
+
+	service.Emit(config.EventTaskCompleted, &models.Task{ID: "task-1", FilePath: "/media/Movie.mkv"})
+	waitDeliveries(t, service, 1)
+
+	requests := server.received()
+	if len(requests) != 1 {
+		t.Fatalf("server got %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(req.body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.header.Get("X-Webhook-Signature-256") != want {
+		t.Errorf("X-Webhook-Signature-256 = %q, want %q", req.header.Get("X-Webhook-Signature-256"), want)
+	}
+	if req.header.Get("X-Webhook-Event") != config.EventTaskCompleted || req.payload.Event != config.EventTaskCompleted {
+		t.Errorf("event = %q / %q, want %s", req.header.Get("X-Webhook-Event"), req.payload.Event, config.EventTaskCompleted)
+	}
+	if req.payload.Task.ID != "task-1" {
+		t.Errorf("payload task = %q, want task-1", req.payload.Task.ID)
+	}
+}
+
+func TestWebhookEventFilter(t *testing.T) {
+	server := newWebhookServer(t)
+	webhook := testWebhook("completed-only", server.URL, 0)
+	webhook.Events = []string{config.EventTaskCompleted}
+	service := NewWebhookService([]config.WebhookConfig{webhook}, newTestDB(t, t.TempDir()))
+
+	task := &models.Task{ID: "task-1"}
+	service.Emit(config.EventTaskFailed, task)
+	service.EmitProgress(task, 0, 60)
+	service.Emit(config.EventTaskCompleted, task)
+	deliveries := waitDeliveries(t, service, 1)
+
+	// Отфильтрованные события не доходят даже до журнала
+	if len(deliveries) != 1 || deliveries[0].Event != config.EventTaskCompleted {
+		t.Errorf("deliveries = %+v, want only %s", deliveries, config.EventTaskCompleted)
+	}
+	for _, req := range server.received() {
+		if req.payload.Event != config.EventTaskCompleted {
+			t.Errorf("filtered event %s was sent", req.payload.Event)
+		}
+	}
+}
+
+func TestWebhookProgressMilestones(t *testing.T) {
+	tests := []struct {
+		name string
+		// progress - последовательные значения прогресса задачи
+		progress []int
+		want     []int
+	}{
+		{name: "small steps", progress: []int{0, 10, 25, 25, 26, 49, 50, 51, 74, 76, 99, 100}, want: []int{25, 50, 75}},
+		{name: "repeated values", progress: []int{0, 30, 30, 30, 30}, want: []int{25}},
+		// За один скачок отправляется только наибольший пройденный порог
+		{name: "jump over several", progress: []int{0, 80, 90}, want: []int{75}},
+		{name: "no milestone passed", progress: []int{0, 10, 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newWebhookServer(t)
+			service := NewWebhookService([]config.WebhookConfig{testWebhook("progress", server.URL, 0)}, newTestDB(t, t.TempDir()))
+
+			task := &models.Task{ID: "task-1"}
+			for i := 1; i < len(tt.progress); i++ {
+				service.EmitProgress(task, tt.progress[i-1], tt.progress[i])
+			}
+			// Завершающее событие дает чего ждать и в случае без пройденных порогов
+			service.Emit(config.EventTaskCompleted, task)
+			waitDeliveries(t, service, len(tt.want)+1)
+
+			got := make(map[int]int)
+			for _, req := range server.received() {
+				if req.payload.Event == config.EventTaskProgress {
+					got[req.payload.Milestone]++
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("milestones = %v, want %v", got, tt.want)
+			}
+			for _, milestone := range tt.want {
+				if got[milestone] != 1 {
+					t.Errorf("milestone %d sent %d times, want 1", milestone, got[milestone])
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookDeliveryLog(t *testing.T) {
+	server := newWebhookServer(t)
+	dir := t.TempDir()
+	webhooks := []config.WebhookConfig{
+		testWebhook("failing", server.URL+"/fail", 1),
+		testWebhook("working", server.URL+"/ok", 1),
+	}
+	service := NewWebhookService(webhooks, newTestDB(t, dir))
+
+	service.Emit(config.EventTaskFailed, &models.Task{ID: "task-1"})
+	waitDeliveries(t, service, 2)
+
+	byWebhook := make(map[string]WebhookDelivery)
+	for _, delivery := range service.GetDeliveries(0) {
+		byWebhook[delivery.Webhook] = delivery
+	}
+
+	failed := byWebhook["failing"]
+	if failed.Status != NotificationFailed || failed.StatusCode != http.StatusInternalServerError ||
+		failed.Attempts != 2 || failed.Error == "" || failed.FinishedAt == nil {
+		t.Errorf("failed delivery = %+v, want failed after 2 attempts with HTTP 500", failed)
+	}
+	ok := byWebhook["working"]
+	if ok.Status != NotificationOK || ok.StatusCode != http.StatusOK || ok.Attempts != 1 || ok.Error != "" {
+		t.Errorf("successful delivery = %+v, want ok after 1 attempt", ok)
+	}
+	if failed.TaskID != "task-1" || failed.Event != config.EventTaskFailed || failed.ID == "" || failed.ID == ok.ID {
+		t.Errorf("delivery ids and fields = %+v / %+v", failed, ok)
+	}
+
+	// Журнал сохраняется в state.json и читается при следующем запуске
+	reopened := NewWebhookService(webhooks, newTestDB(t, dir))
+	if got := reopened.GetDeliveries(0); len(got) != 2 {
+		t.Errorf("deliveries after reopen = %d, want 2", len(got))
+	}
+}
+
+func TestWebhookDeliveryLogLimit(t *testing.T) {
+	service := NewWebhookService(nil, newTestDB(t, t.TempDir()))
+
+	for i := 0; i < webhookDeliveryLogSize+5; i++ {
+		service.record(WebhookDelivery{ID: fmt.Sprintf("delivery-%d", i), Attempts: i})
+	}
+
+	deliveries := service.GetDeliveries(0)
+	if len(deliveries) != webhookDeliveryLogSize {
+		t.Fatalf("log size = %d, want %d", len(deliveries), webhookDeliveryLogSize)
+	}
+	// Новые записи первыми, самые старые вытеснены
+	if deliveries[0].Attempts != webhookDeliveryLogSize+4 || deliveries[len(deliveries)-1].Attempts != 5 {
+		t.Errorf("log keeps attempts %d..%d, want %d..5",
+			deliveries[0].Attempts, deliveries[len(deliveries)-1].Attempts, webhookDeliveryLogSize+4)
+	}
+	if got := service.GetDeliveries(10); len(got) != 10 || got[0].Attempts != webhookDeliveryLogSize+4 {
+		t.Errorf("GetDeliveries(10) = %d entries, want the 10 newest", len(got))
+	}
+}
@@ -2,15 +2,16 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
+	"ultimate-dts-fix-server/backend/config"
+	"ultimate-dts-fix-server/backend/database"
 	"ultimate-dts-fix-server/backend/models"
 
 	"github.com/gorilla/websocket"
@@ -35,11 +36,26 @@ type WebSocketService struct {
 	upgrader         websocket.Upgrader
 	queueService     *QueueService
 	converterService *ConverterService
+	notifierService  *NotifierService
+	webhookService   *WebhookService
+	mqttService      *MQTTService
+	emailService     *EmailService
+	libraryScanner   *LibraryScanner
+	historyService   *HistoryService
+	statsService     *StatsService
+
+	// Фоновые поиски файлов по идентификатору
+	searchRoots  []string
+	searchConfig config.SearchConfig
+	searches     map[string]*searchJob
+	searchSeq    uint64
+	searchesMux  sync.Mutex
 }
 
 func NewWebSocketService() *WebSocketService {
 	return &WebSocketService{
-		clients: make(map[*websocket.Conn]bool),
+		clients:  make(map[*websocket.Conn]bool),
+		searches: make(map[string]*searchJob),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true
@@ -54,6 +70,47 @@ func (s *WebSocketService) SetServices(queueService *QueueService, converterServ
 	s.converterService = converterService
 }
 
+// SetNotifierService устанавливает сервис уведомлений медиасерверов
+func (s *WebSocketService) SetNotifierService(notifierService *NotifierService) {
+	s.notifierService = notifierService
+}
+
+// SetWebhookService устанавливает сервис внешних webhooks
+func (s *WebSocketService) SetWebhookService(webhookService *WebhookService) {
+	s.webhookService = webhookService
+}
+
+// SetMQTTService включает зеркалирование состояния очереди в MQTT
+func (s *WebSocketService) SetMQTTService(mqttService *MQTTService) {
+	s.mqttService = mqttService
+}
+
+// SetEmailService устанавливает сервис почтовых уведомлений
+func (s *WebSocketService) SetEmailService(emailService *EmailService) {
+	s.emailService = emailService
+}
+
+// SetLibraryScanner устанавливает сканер медиатеки
+func (s *WebSocketService) SetLibraryScanner(libraryScanner *LibraryScanner) {
+	s.libraryScanner = libraryScanner
+}
+
+// SetHistoryService устанавливает сервис хранения истории
+func (s *WebSocketService) SetHistoryService(historyService *HistoryService) {
+	s.historyService = historyService
+}
+
+// SetStatsService устанавливает сервис статистики
+func (s *WebSocketService) SetStatsService(statsService *StatsService) {
+	s.statsService = statsService
+}
+
+// SetSearchConfig задает медиатеки и ограничения поиска файлов
+func (s *WebSocketService) SetSearchConfig(roots []string, searchConfig config.SearchConfig) {
+	s.searchRoots = roots
+	s.searchConfig = searchConfig
+}
+
 func (s *WebSocketService) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -97,35 +154,38 @@ func (s *WebSocketService) sendInitialState(conn *websocket.Conn) {
 		return
 	}
 
-	tasks, _ := s.queueService.GetQueue()
-
-	var queueTasks, historyTasks []*models.Task
-	for _, task := range tasks {
-		if task.Status == models.StatusPending || task.Status == models.StatusProcessing {
-			queueTasks = append(queueTasks, task)
-		} else if task.Status == models.StatusCompleted || task.Status == models.StatusError {
-			historyTasks = append(historyTasks, task)
-		}
+	// Очередь целиком, история - только первая страница, остальное через list_tasks
+	queueTasks, _ := s.queueService.GetQueue()
+	history, err := s.queueService.ListTasks(database.TaskQuery{Statuses: models.FinishedStatuses})
+	if err != nil {
+		log.Printf("Ошибка получения истории: %v", err)
+		history = &database.TaskPage{}
 	}
 
 	var activeTask *models.Task
+	var schedule *ScheduleState
 	if s.converterService != nil {
 		activeTask = s.converterService.GetActiveTask()
+		state := s.converterService.GetScheduleState()
+		schedule = &state
 	}
 
 	response := WSResponse{
 		Type: "initial_state",
 		Data: map[string]interface{}{
-			"queue":      queueTasks,
-			"history":    historyTasks,
-			"activeTask": activeTask,
-			"status":     "online",
-			"timestamp":  time.Now().Unix(),
+			"queue":             queueTasks,
+			"history":           history.Tasks,
+			"historyNextCursor": history.NextCursor,
+			"historyTotal":      history.Total,
+			"activeTask":        activeTask,
+			"queuePaused":       s.queueService.IsPaused(),
+			"schedule":          schedule,
+			"status":            "online",
+			"timestamp":         time.Now().Unix(),
 		},
 	}
 
-	jsonData, _ := json.Marshal(response)
-	conn.WriteMessage(websocket.TextMessage, jsonData)
+	s.writeJSON(conn, response)
 }
 
 // handleMessage обрабатывает входящие команды
@@ -138,18 +198,57 @@ func (s *WebSocketService) handleMessage(conn *websocket.Conn, msg *WSMessage) {
 		s.handleGetState(conn, msg, &response)
 	case "search_files":
 		s.handleSearchFiles(conn, msg, &response)
+	case "cancel_search":
+		s.handleCancelSearch(conn, msg, &response)
+	case "list_directory":
+		s.handleListDirectory(conn, msg, &response)
+	case "start_scan":
+		s.handleStartScan(conn, msg, &response)
+	case "cancel_scan":
+		s.handleCancelScan(conn, msg, &response)
+	case "get_scan_state":
+		response.Data = s.libraryScanner.State()
+	case "query_inventory":
+		s.handleQueryInventory(conn, msg, &response)
+	case "list_tasks":
+		s.handleListTasks(conn, msg, &response)
+	case "find_tasks":
+		s.handleFindTasks(conn, msg, &response)
+	case "purge_history":
+		s.handlePurgeHistory(conn, msg, &response)
+	case "get_stats":
+		s.handleGetStats(conn, msg, &response)
 	case "add_task":
 		s.handleAddTask(conn, msg, &response)
+	case "add_tasks":
+		s.handleAddTasks(conn, msg, &response)
+	case "preview_output_name":
+		s.handlePreviewOutputName(conn, msg, &response)
 	case "cancel_task":
 		s.handleCancelTask(conn, msg, &response)
 	case "delete_task":
 		s.handleDeleteTask(conn, msg, &response)
+	case "revert_task":
+		s.handleRevertTask(conn, msg, &response)
+	case "test_notifier":
+		s.handleTestNotifier(conn, msg, &response)
+	case "test_email":
+		s.handleTestEmail(conn, msg, &response)
+	case "get_webhook_deliveries":
+		s.handleGetWebhookDeliveries(conn, msg, &response)
+	case "pause_task":
+		s.handlePauseTask(conn, msg, &response)
+	case "resume_task":
+		s.handleResumeTask(conn, msg, &response)
+	case "pause_queue":
+		s.handlePauseQueue(conn, msg, &response)
+	case "resume_queue":
+		s.handleResumeQueue(conn, msg, &response)
 	default:
 		response.Error = "Unknown command: " + msg.Type
 	}
 
-	jsonData, _ := json.Marshal(response)
-	conn.WriteMessage(websocket.TextMessage, jsonData)
+	s.writeJSON(conn, response)
 }
 
 // handleGetState возвращает текущее состояние
@@ -157,26 +256,230 @@ func (s *WebSocketService) handleGetState(conn *websocket.Conn, msg *WSMessage,
 	s.sendInitialState(conn)
 }
 
-// handleSearchFiles ищет файлы
+// handleSearchFiles запускает фоновый поиск файлов. Результаты приходят
+// сообщениями search_progress с идентификатором поиска из ответа
 func (s *WebSocketService) handleSearchFiles(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
 	pattern, _ := msg.Data["pattern"].(string)
 	if pattern == "" {
 		pattern = "DTS.*5\\.1"
 	}
 
-	files, err := searchVideoFiles("/media", pattern)
+	// Клиент может только уменьшить ограничение из конфигурации
+	maxResults := s.searchConfig.MaxResults
+	if limit, ok := msg.Data["maxResults"].(float64); ok && limit > 0 && (maxResults <= 0 || int(limit) < maxResults) {
+		maxResults = int(limit)
+	}
+
+	job := s.startSearch(conn, pattern, maxResults)
+	response.Data = map[string]interface{}{
+		"searchId":   job.id,
+		"pattern":    pattern,
+		"roots":      s.searchRoots,
+		"maxResults": maxResults,
+	}
+}
+
+// handleCancelSearch отменяет поиск по searchId, без него - все поиски клиента
+func (s *WebSocketService) handleCancelSearch(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	searchID, _ := msg.Data["searchId"].(string)
+	if s.cancelSearches(conn, searchID) == 0 {
+		response.Error = "Поиск не выполняется"
+		return
+	}
+
+	response.Data = map[string]interface{}{
+		"searchId": searchID,
+		"message":  "Поиск отменяется",
+	}
+}
+
+// handleListDirectory возвращает содержимое директории медиатеки, без path - список медиатек
+func (s *WebSocketService) handleListDirectory(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	path, _ := msg.Data["path"].(string)
+	if path == "" {
+		response.Data = s.listRoots()
+		return
+	}
+
+	recursive, _ := msg.Data["recursive"].(bool)
+	listing, err := s.listDirectory(path, recursive)
 	if err != nil {
-		response.Error = "Ошибка поиска: " + err.Error()
+		response.Error = err.Error()
+		return
+	}
+	response.Data = listing
+}
+
+// handleStartScan запускает фоновое сканирование аудиодорожек медиатеки
+func (s *WebSocketService) handleStartScan(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	force, _ := msg.Data["force"].(bool)
+	if err := s.libraryScanner.StartScan(force); err != nil {
+		response.Error = err.Error()
 		return
 	}
 
+	s.BroadcastLog("Сканирование медиатеки запущено", "info")
+	response.Data = map[string]interface{}{
+		"message": "Сканирование запущено",
+	}
+}
+
+// handleCancelScan останавливает сканирование медиатеки
+func (s *WebSocketService) handleCancelScan(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	if err := s.libraryScanner.CancelScan(); err != nil {
+		response.Error = err.Error()
+		return
+	}
+
+	response.Data = map[string]interface{}{
+		"message": "Сканирование отменяется",
+	}
+}
+
+// handleQueryInventory ищет файлы в описи медиатеки по аудиодорожкам
+func (s *WebSocketService) handleQueryInventory(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	var query InventoryQuery
+	query.Codec, _ = msg.Data["codec"].(string)
+	query.Profile, _ = msg.Data["profile"].(string)
+	query.Layout, _ = msg.Data["layout"].(string)
+	query.Language, _ = msg.Data["language"].(string)
+	query.AnyStream, _ = msg.Data["anyStream"].(bool)
+	query.NotConverted, _ = msg.Data["notConverted"].(bool)
+	if limit, ok := msg.Data["limit"].(float64); ok {
+		query.Limit = int(limit)
+	}
+
+	files, total := s.libraryScanner.Query(query)
 	response.Data = map[string]interface{}{
 		"files": files,
 		"count": len(files),
+		"total": total,
+		"scan":  s.libraryScanner.State(),
+	}
+}
+
+// handleListTasks возвращает страницу задач с фильтрами по статусу, времени, профилю и пути
+func (s *WebSocketService) handleListTasks(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	query, err := parseTaskQuery(msg)
+	if err != nil {
+		response.Error = err.Error()
+		return
+	}
+	query.Sort, _ = msg.Data["sort"].(string)
+	if order, _ := msg.Data["order"].(string); order == "asc" {
+		query.Asc = true
+	}
+	query.Cursor, _ = msg.Data["cursor"].(string)
+	if limit, ok := msg.Data["limit"].(float64); ok {
+		query.Limit = int(limit)
+	}
+
+	page, err := s.queueService.ListTasks(query)
+	if err != nil {
+		response.Error = err.Error()
+		return
+	}
+	response.Data = page
+}
+
+// handlePurgeHistory удаляет завершенные задачи из истории по фильтру или по правилам
+// history (policy). По умолчанию задачи переносятся в архив, с discard - удаляются
+func (s *WebSocketService) handlePurgeHistory(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	if s.historyService == nil {
+		response.Error = "Сервис истории не запущен"
+		return
+	}
+	dryRun, _ := msg.Data["dryRun"].(bool)
+
+	var result *PurgeResult
+	var err error
+	if policy, _ := msg.Data["policy"].(bool); policy {
+		result, err = s.historyService.ApplyRetention(dryRun)
+	} else {
+		var query database.TaskQuery
+		if query, err = parseTaskQuery(msg); err == nil {
+			discard, _ := msg.Data["discard"].(bool)
+			result, err = s.historyService.Purge(query, discard, dryRun)
+		}
+	}
+	if err != nil {
+		response.Error = err.Error()
+		return
+	}
+
+	if result.Removed > 0 {
+		action := "перенесено в архив"
+		if !result.Archived {
+			action = "удалено"
+		}
+		s.BroadcastLog(fmt.Sprintf("Очистка истории: %s задач %d", action, result.Removed), "info")
+	}
+	response.Data = result
+}
+
+// handleGetStats возвращает статистику конвертаций, days - сколько дней в разбивке по дням
+func (s *WebSocketService) handleGetStats(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	if s.statsService == nil {
+		response.Error = "Сервис статистики не запущен"
+		return
+	}
+
+	days := 0
+	if value, ok := msg.Data["days"].(float64); ok {
+		days = int(value)
+	}
+
+	report, err := s.statsService.Report(days)
+	if err != nil {
+		response.Error = err.Error()
+		return
 	}
+	response.Data = report
 }
 
-// handleAddTask добавляет задачу
+// parseTaskQuery читает фильтр задач: statuses, from и to в RFC 3339, profile, path
+func parseTaskQuery(msg *WSMessage) (database.TaskQuery, error) {
+	var query database.TaskQuery
+	if rawStatuses, ok := msg.Data["statuses"].([]interface{}); ok {
+		for _, raw := range rawStatuses {
+			if status, ok := raw.(string); ok && status != "" {
+				query.Statuses = append(query.Statuses, models.TaskStatus(status))
+			}
+		}
+	}
+	for field, target := range map[string]**time.Time{"from": &query.From, "to": &query.To} {
+		value, _ := msg.Data[field].(string)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return query, fmt.Errorf("%s: ожидается время в формате RFC 3339", field)
+		}
+		*target = &t
+	}
+	query.Profile, _ = msg.Data["profile"].(string)
+	query.Path, _ = msg.Data["path"].(string)
+	return query, nil
+}
+
+// handleFindTasks ищет задачи по пути исходного или выходного файла
+func (s *WebSocketService) handleFindTasks(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	path, ok := msg.Data["path"].(string)
+	if !ok || path == "" {
+		response.Error = "path required"
+		return
+	}
+
+	tasks := s.queueService.FindTasksByPath(path)
+	response.Data = map[string]interface{}{
+		"path":  path,
+		"tasks": tasks,
+		"count": len(tasks),
+	}
+}
+
+// handleAddTask добавляет задачу. Уже сконвертированный файл добавляется только с force
 func (s *WebSocketService) handleAddTask(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
 	filePath, ok := msg.Data["filePath"].(string)
 	if !ok || filePath == "" {
@@ -184,44 +487,125 @@ func (s *WebSocketService) handleAddTask(conn *websocket.Conn, msg *WSMessage, r
 		return
 	}
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		response.Error = "Файл не существует"
+	profileName, _ := msg.Data["profile"].(string)
+	task, err := s.converterService.prepareTask(filePath, profileName)
+	if err != nil {
+		response.Error = err.Error()
+		return
+	}
+
+	force, _ := msg.Data["force"].(bool)
+	if err := s.queueService.Enqueue(task, force); err != nil {
+		response.Error = err.Error()
+		// По дубликату интерфейс может предложить повторить с force
+		var duplicate *DuplicateError
+		if errors.As(err, &duplicate) {
+			response.Data = map[string]interface{}{
+				"filePath":  filePath,
+				"duplicate": duplicate,
+			}
+		}
 		return
 	}
+	s.BroadcastLog("Задача добавлена: "+filePath, "info")
 
-	if !isVideoFile(filePath) {
-		response.Error = "Файл не является видеофайлом"
+	response.Data = map[string]interface{}{
+		"taskId":        task.ID,
+		"estimatedSize": task.EstimatedSize,
+		"message":       "Задача добавлена",
+	}
+}
+
+// handleAddTasks добавляет пакет файлов из filePaths или директорию directory
+// (recursive - с поддиректориями) и возвращает итог по каждому файлу
+func (s *WebSocketService) handleAddTasks(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	profileName, _ := msg.Data["profile"].(string)
+	if _, err := s.converterService.Profile(profileName); err != nil {
+		response.Error = err.Error()
 		return
 	}
+	force, _ := msg.Data["force"].(bool)
 
-	audioInfo, err := getAudioInfo(filePath)
-	if err != nil {
-		response.Error = "Ошибка получения аудио информации"
+	var results []EnqueueResult
+	truncated := false
+	if directory, _ := msg.Data["directory"].(string); directory != "" {
+		recursive, _ := msg.Data["recursive"].(bool)
+		var err error
+		results, truncated, err = s.enqueueDirectory(directory, recursive, profileName, force)
+		if err != nil {
+			response.Error = err.Error()
+			return
+		}
+	} else {
+		rawPaths, _ := msg.Data["filePaths"].([]interface{})
+		var filePaths []string
+		for _, raw := range rawPaths {
+			if filePath, ok := raw.(string); ok && filePath != "" {
+				filePaths = append(filePaths, filePath)
+			}
+		}
+		if len(filePaths) == 0 {
+			response.Error = "filePaths or directory required"
+			return
+		}
+		results = s.enqueueFiles(filePaths, profileName, force)
+	}
+
+	added := 0
+	for _, result := range results {
+		if result.Status == EnqueueAdded {
+			added++
+		}
+	}
+	if added > 0 {
+		s.BroadcastLog(fmt.Sprintf("Добавлено задач: %d из %d", added, len(results)), "info")
+	}
+
+	response.Data = map[string]interface{}{
+		"results":   results,
+		"added":     added,
+		"skipped":   len(results) - added,
+		"truncated": truncated,
+	}
+}
+
+// handlePreviewOutputName показывает имя результата до добавления в очередь
+func (s *WebSocketService) handlePreviewOutputName(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	filePath, ok := msg.Data["filePath"].(string)
+	if !ok || filePath == "" {
+		response.Error = "filePath required"
+		return
+	}
+
+	profileName, _ := msg.Data["profile"].(string)
+	if _, err := s.converterService.Profile(profileName); err != nil {
+		response.Error = err.Error()
 		return
 	}
 
-	task := &models.Task{
-		ID:        time.Now().Format("20060102150405"),
-		FilePath:  filePath,
-		Status:    models.StatusPending,
-		Progress:  0,
-		CreatedAt: time.Now(),
-		AudioInfo: &models.AudioInfo{
+	// Аудио нужно для переменных {sourceCodec} и {sourceLayout}, но не обязательно
+	var audio *models.AudioInfo
+	if audioInfo, err := s.converterService.getAudioInfo(filePath); err == nil && audioInfo != nil {
+		audio = &models.AudioInfo{
 			CodecName:     audioInfo.CodecName,
 			ChannelLayout: audioInfo.ChannelLayout,
 			Channels:      audioInfo.Channels,
 			SampleRate:    audioInfo.SampleRate,
 			BitRate:       audioInfo.BitRate,
-		},
+		}
 	}
 
-	s.queueService.AddTask(task)
-	s.BroadcastLog("Задача добавлена: "+filePath, "info")
-
-	response.Data = map[string]interface{}{
-		"taskId":  task.ID,
-		"message": "Задача добавлена",
+	outputPath, err := s.converterService.PreviewOutputPath(filePath, profileName, audio)
+	data := map[string]interface{}{
+		"filePath":   filePath,
+		"outputPath": outputPath,
+		"outputName": filepath.Base(outputPath),
 	}
+	if err != nil {
+		// Имя занято и политика коллизий не позволит конвертацию - сообщаем причину
+		data["conflict"] = err.Error()
+	}
+	response.Data = data
 }
 
 // handleCancelTask отменяет задачу
@@ -244,6 +628,155 @@ func (s *WebSocketService) handleCancelTask(conn *websocket.Conn, msg *WSMessage
 	}
 }
 
+// handlePauseTask приостанавливает активную конвертацию
+func (s *WebSocketService) handlePauseTask(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	taskID, ok := msg.Data["taskId"].(string)
+	if !ok || taskID == "" {
+		response.Error = "taskId required"
+		return
+	}
+
+	if err := s.converterService.PauseConversion(taskID); err != nil {
+		response.Error = err.Error()
+		return
+	}
+
+	s.BroadcastLog("Задача приостановлена: "+taskID, "warning")
+	response.Data = map[string]interface{}{
+		"message": "Задача приостановлена",
+	}
+}
+
+// handleResumeTask возобновляет приостановленную конвертацию
+func (s *WebSocketService) handleResumeTask(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	taskID, ok := msg.Data["taskId"].(string)
+	if !ok || taskID == "" {
+		response.Error = "taskId required"
+		return
+	}
+
+	if err := s.converterService.ResumeConversion(taskID); err != nil {
+		response.Error = err.Error()
+		return
+	}
+
+	s.BroadcastLog("Задача возобновлена: "+taskID, "info")
+	response.Data = map[string]interface{}{
+		"message": "Задача возобновлена",
+	}
+}
+
+// handlePauseQueue приостанавливает запуск новых задач
+func (s *WebSocketService) handlePauseQueue(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	if err := s.queueService.PauseQueue(); err != nil {
+		response.Error = "Ошибка сохранения состояния очереди: " + err.Error()
+		return
+	}
+
+	s.BroadcastLog("Очередь приостановлена", "warning")
+	response.Data = map[string]interface{}{
+		"message": "Очередь приостановлена",
+	}
+}
+
+// handleResumeQueue возобновляет запуск новых задач
+func (s *WebSocketService) handleResumeQueue(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	if err := s.queueService.ResumeQueue(); err != nil {
+		response.Error = "Ошибка сохранения состояния очереди: " + err.Error()
+		return
+	}
+
+	s.BroadcastLog("Очередь возобновлена", "info")
+	response.Data = map[string]interface{}{
+		"message": "Очередь возобновлена",
+	}
+}
+
+// handleRevertTask откатывает завершенную конвертацию
+func (s *WebSocketService) handleRevertTask(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	taskID, ok := msg.Data["taskId"].(string)
+	if !ok || taskID == "" {
+		response.Error = "taskId required"
+		return
+	}
+
+	quarantineOutput, _ := msg.Data["quarantineOutput"].(bool)
+
+	task, err := s.converterService.RevertTask(taskID, quarantineOutput)
+	if err != nil {
+		response.Error = err.Error()
+		return
+	}
+
+	s.BroadcastLog("Конвертация откачена: "+task.FilePath, "warning")
+	response.Data = map[string]interface{}{
+		"message": "Конвертация откачена",
+		"task":    task,
+	}
+}
+
+// handleTestNotifier отправляет пробное уведомление медиасерверу
+func (s *WebSocketService) handleTestNotifier(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	name, ok := msg.Data["name"].(string)
+	if !ok || name == "" {
+		response.Error = "name required"
+		return
+	}
+
+	dir, ok := msg.Data["path"].(string)
+	if !ok || dir == "" {
+		response.Error = "path required"
+		return
+	}
+
+	attempts, err := s.notifierService.TestNotifier(name, dir)
+	if err != nil {
+		response.Error = err.Error()
+		return
+	}
+
+	response.Data = map[string]interface{}{
+		"message":  "Уведомление доставлено",
+		"attempts": attempts,
+	}
+}
+
+// handleTestEmail отправляет пробное письмо или сводку за последние сутки
+func (s *WebSocketService) handleTestEmail(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	if s.emailService == nil {
+		response.Error = "Отправка писем не настроена"
+		return
+	}
+
+	var err error
+	if digest, _ := msg.Data["digest"].(bool); digest {
+		now := time.Now()
+		err = s.emailService.SendDigest(now.AddDate(0, 0, -1), now)
+	} else {
+		err = s.emailService.Send("Проверка уведомлений DTS Converter", "Отправка писем настроена правильно.\n")
+	}
+	if err != nil {
+		response.Error = err.Error()
+		return
+	}
+
+	response.Data = map[string]interface{}{
+		"message": "Письмо отправлено",
+	}
+}
+
+// handleGetWebhookDeliveries возвращает журнал доставок webhooks, новые записи первыми
+func (s *WebSocketService) handleGetWebhookDeliveries(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
+	limit := 50
+	if value, ok := msg.Data["limit"].(float64); ok && value > 0 {
+		limit = int(value)
+	}
+
+	response.Data = map[string]interface{}{
+		"deliveries": s.webhookService.GetDeliveries(limit),
+	}
+}
+
 // handleDeleteTask удаляет задачу
 func (s *WebSocketService) handleDeleteTask(conn *websocket.Conn, msg *WSMessage, response *WSResponse) {
 	taskID, ok := msg.Data["taskId"].(string)
@@ -260,7 +793,7 @@ func (s *WebSocketService) handleDeleteTask(conn *websocket.Conn, msg *WSMessage
 		return
 	}
 
-	if task.Status == models.StatusProcessing {
+	if task.Status == models.StatusProcessing || task.Status == models.StatusPaused {
 		activeTask := s.converterService.GetActiveTask()
 		if activeTask != nil && activeTask.ID == task.ID && !force {
 			response.Error = "Задача в процессе. Используйте force=true"
@@ -284,43 +817,6 @@ func (s *WebSocketService) handleDeleteTask(conn *websocket.Conn, msg *WSMessage
 
 // Вспомогательные функции
 
-func searchVideoFiles(rootPath, pattern string) ([]map[string]interface{}, error) {
-	var results []map[string]interface{}
-
-	re, err := regexp.Compile("(?i)" + pattern)
-	if err != nil {
-		re = nil
-	}
-
-	err = filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() || !isVideoFile(path) {
-			return nil
-		}
-
-		fileName := filepath.Base(path)
-		matched := false
-
-		if re != nil {
-			matched = re.MatchString(fileName)
-		} else {
-			matched = strings.Contains(strings.ToLower(fileName), strings.ToLower(pattern))
-		}
-
-		if matched {
-			results = append(results, map[string]interface{}{
-				"path":     path,
-				"name":     fileName,
-				"size":     info.Size(),
-				"modified": info.ModTime().Unix(),
-			})
-		}
-
-		return nil
-	})
-
-	return results, err
-}
-
 func isVideoFile(filePath string) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
 	videoExts := []string{".mkv", ".mp4", ".avi", ".mov", ".wmv", ".flv", ".webm", ".m4v"}
@@ -332,37 +828,35 @@ func isVideoFile(filePath string) bool {
 	return false
 }
 
-func getAudioInfo(filePath string) (*AudioStreamInfo, error) {
-	cmd := exec.Command("ffprobe",
-		"-v", "quiet",
-		"-print_format", "json",
-		"-show_streams",
-		"-select_streams", "a:0",
-		filePath,
-	)
+func (s *WebSocketService) removeClient(conn *websocket.Conn) {
+	s.cancelSearches(conn, "")
 
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
-	}
+	s.clientsMux.Lock()
+	delete(s.clients, conn)
+	s.clientsMux.Unlock()
+	log.Printf("WebSocket клиент отключен. Осталось клиентов: %d", len(s.clients))
+}
 
-	var probeOutput FFProbeOutput
-	if err := json.Unmarshal(output, &probeOutput); err != nil {
-		return nil, err
-	}
+// sendTo отправляет сообщение одному клиенту
+func (s *WebSocketService) sendTo(conn *websocket.Conn, messageType string, data interface{}) error {
+	return s.writeJSON(conn, map[string]interface{}{
+		"type": messageType,
+		"data": data,
+	})
+}
 
-	if len(probeOutput.Streams) == 0 {
-		return nil, nil
+// writeJSON пишет сообщение в соединение. Запись идет под той же блокировкой, что и
+// рассылка, потому что соединение не допускает одновременных писателей
+func (s *WebSocketService) writeJSON(conn *websocket.Conn, v interface{}) error {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Ошибка маршалинга WebSocket сообщения: %v", err)
+		return err
 	}
 
-	return &probeOutput.Streams[0], nil
-}
-
-func (s *WebSocketService) removeClient(conn *websocket.Conn) {
 	s.clientsMux.Lock()
-	delete(s.clients, conn)
-	s.clientsMux.Unlock()
-	log.Printf("WebSocket клиент отключен. Осталось клиентов: %d", len(s.clients))
+	defer s.clientsMux.Unlock()
+	return conn.WriteMessage(websocket.TextMessage, jsonData)
 }
 
 func (s *WebSocketService) BroadcastMessage(messageType string, data interface{}) {
@@ -394,6 +888,10 @@ func (s *WebSocketService) BroadcastQueueUpdate(tasks []*models.Task) {
 	s.BroadcastMessage("queue_update", map[string]interface{}{
 		"queue": tasks,
 	})
+
+	if s.mqttService != nil {
+		s.mqttService.PublishQueue(tasks)
+	}
 }
 
 func (s *WebSocketService) BroadcastConversionProgress(taskID string, progress int, status models.TaskStatus, message string) {
@@ -403,6 +901,24 @@ func (s *WebSocketService) BroadcastConversionProgress(taskID string, progress i
 		"status":   status,
 		"message":  message,
 	})
+
+	if s.mqttService != nil {
+		s.mqttService.PublishProgress(taskID, progress, status, message)
+	}
+}
+
+func (s *WebSocketService) BroadcastQueueState(paused bool) {
+	s.BroadcastMessage("queue_state", map[string]interface{}{
+		"paused": paused,
+	})
+
+	if s.mqttService != nil {
+		s.mqttService.PublishQueueState()
+	}
+}
+
+func (s *WebSocketService) BroadcastScheduleState(state ScheduleState) {
+	s.BroadcastMessage("schedule_state", state)
 }
 
 func (s *WebSocketService) BroadcastScanProgress(progress int, message string, completed bool) {
@@ -5,20 +5,42 @@ class DTSConverterApp {
         this.isConnected = false;
         this.queue = [];
         this.history = [];
+        this.historyTotal = 0;
+        this.historyCursor = '';
+        this.historyAppend = false;
+        this.queueSignature = '';
         this.activeTask = null;
+        this.queuePaused = false;
         this.searchResults = [];
+        this.searchId = null;
+        this.searchRunning = false;
+        this.browser = null;
         this.init();
     }
 
     init() {
         this.setupEventListeners();
         this.connectWebSocket();
+        this.loadStats();
     }
 
     setupEventListeners() {
         const filePathInput = document.getElementById('file-path-input');
         const searchFilesBtn = document.getElementById('search-files-btn');
         const closeSearchBtn = document.getElementById('close-search-btn');
+        const addAllSearchBtn = document.getElementById('add-all-search-btn');
+        const browseBtn = document.getElementById('browse-btn');
+        const closeBrowserBtn = document.getElementById('close-browser-btn');
+        const browserEnqueueBtn = document.getElementById('browser-enqueue-btn');
+        const queuePauseBtn = document.getElementById('queue-pause-btn');
+        const historyStatusFilter = document.getElementById('history-status-filter');
+        const historyPathFilter = document.getElementById('history-path-filter');
+        const historyMoreBtn = document.getElementById('history-more-btn');
+        const historyPurgeBtn = document.getElementById('history-purge-btn');
+        const importBtn = document.getElementById('import-btn');
+        const statsRefreshBtn = document.getElementById('stats-refresh-btn');
+        const scanLibraryBtn = document.getElementById('scan-library-btn');
+        const queryInventoryBtn = document.getElementById('query-inventory-btn');
 
         searchFilesBtn.addEventListener('click', () => {
             this.searchFiles();
@@ -28,6 +50,74 @@ class DTSConverterApp {
             this.closeSearchResults();
         });
 
+        addAllSearchBtn.addEventListener('click', () => {
+            this.addAllSearchResults();
+        });
+
+        browseBtn.addEventListener('click', () => {
+            this.browseDirectory('');
+        });
+
+        closeBrowserBtn.addEventListener('click', () => {
+            this.closeBrowser();
+        });
+
+        browserEnqueueBtn.addEventListener('click', () => {
+            this.enqueueBrowsedDirectory();
+        });
+
+        queuePauseBtn.addEventListener('click', () => {
+            this.toggleQueuePause();
+        });
+
+        historyStatusFilter.addEventListener('change', () => {
+            this.loadHistory(false);
+        });
+
+        historyPathFilter.addEventListener('keypress', (event) => {
+            if (event.key === 'Enter') {
+                this.loadHistory(false);
+            }
+        });
+
+        historyMoreBtn.addEventListener('click', () => {
+            this.loadHistory(true);
+        });
+
+        importBtn.addEventListener('click', () => {
+            this.importBundle();
+        });
+
+        document.getElementById('export-btn').addEventListener('click', () => {
+            this.exportBundle();
+        });
+
+        document.getElementById('history-archive-btn').addEventListener('click', () => {
+            this.downloadArchive();
+        });
+
+        statsRefreshBtn.addEventListener('click', () => {
+            this.loadStats();
+        });
+
+        historyPurgeBtn.addEventListener('click', () => {
+            // Сначала узнаем, сколько задач подходит под фильтр
+            this.sendCommand('purge_history', { ...this.getHistoryFilter(), dryRun: true });
+        });
+
+        scanLibraryBtn.addEventListener('click', () => {
+            this.sendCommand('start_scan');
+        });
+
+        queryInventoryBtn.addEventListener('click', () => {
+            this.sendCommand('query_inventory', {
+                codec: 'dts',
+                profile: 'DTS-HD MA',
+                layout: '5.1',
+                notConverted: true
+            });
+        });
+
         filePathInput.addEventListener('keypress', (event) => {
             if (event.key === 'Enter') {
                 this.searchFiles();
@@ -95,26 +185,64 @@ class DTSConverterApp {
                 this.handleInitialState(data.data);
                 break;
             case 'queue_update':
-                this.loadState();
+                this.handleQueueUpdate(data.data.queue || []);
+                break;
+            case 'list_tasks_response':
+                this.handleListTasksResponse(data);
+                break;
+            case 'purge_history_response':
+                this.handlePurgeHistoryResponse(data);
                 break;
             case 'conversion_progress':
                 this.updateConversionProgress(data.data);
                 break;
+            case 'queue_state':
+                this.updateQueuePaused(data.data.paused);
+                break;
+            case 'schedule_state':
+                this.updateSchedule(data.data);
+                break;
             case 'log':
                 this.addLog(data.data.message, data.data.level);
                 break;
+            case 'scan_progress':
+                this.updateScanProgress(data.data);
+                break;
+            case 'query_inventory_response':
+                this.handleInventoryResponse(data);
+                break;
             case 'search_files_response':
                 this.handleSearchResponse(data);
                 break;
+            case 'search_progress':
+                this.handleSearchProgress(data.data);
+                break;
+            case 'cancel_search_response':
+                break;
+            case 'list_directory_response':
+                this.handleDirectoryResponse(data);
+                break;
             case 'add_task_response':
                 this.handleAddTaskResponse(data);
                 break;
+            case 'add_tasks_response':
+                this.handleAddTasksResponse(data);
+                break;
             case 'cancel_task_response':
                 this.handleCancelTaskResponse(data);
                 break;
             case 'delete_task_response':
                 this.handleDeleteTaskResponse(data);
                 break;
+            case 'start_scan_response':
+            case 'cancel_scan_response':
+            case 'revert_task_response':
+            case 'pause_task_response':
+            case 'resume_task_response':
+            case 'pause_queue_response':
+            case 'resume_queue_response':
+                this.handleCommandResponse(data);
+                break;
             default:
                 console.log('Неизвестный тип сообщения:', data);
         }
@@ -122,14 +250,258 @@ class DTSConverterApp {
 
     handleInitialState(data) {
         this.updateQueue(data.queue || []);
-        this.updateHistory(data.history || []);
+        this.queueSignature = this.getQueueSignature(this.queue);
+
+        // С фильтром или подгруженными страницами историю перезапрашиваем сами
+        if (this.getHistoryFilter().path || this.getHistoryFilter().statuses.length === 1 ||
+            this.history.length > (data.history || []).length) {
+            this.refreshHistory();
+        } else {
+            this.historyTotal = data.historyTotal || 0;
+            this.historyCursor = data.historyNextCursor || '';
+            this.updateHistory(data.history || []);
+        }
         this.updateActiveTask(data.activeTask);
+        this.updateQueuePaused(data.queuePaused);
+        this.updateSchedule(data.schedule);
     }
 
     loadState() {
         this.sendCommand('get_state');
     }
 
+    handleQueueUpdate(queue) {
+        // Состав очереди изменился - задача завершилась или добавлена, обновляем все состояние
+        if (this.getQueueSignature(queue) !== this.queueSignature) {
+            this.loadState();
+            this.loadStats();
+        }
+    }
+
+    getQueueSignature(queue) {
+        return queue.map(task => `${task.id}:${task.status}`).join(',');
+    }
+
+    getHistoryFilter() {
+        const status = document.getElementById('history-status-filter').value;
+        return {
+            statuses: status ? [status] : ['completed', 'error', 'skipped', 'reverted'],
+            path: document.getElementById('history-path-filter').value.trim()
+        };
+    }
+
+    loadHistory(append) {
+        this.historyAppend = append;
+        this.sendCommand('list_tasks', {
+            ...this.getHistoryFilter(),
+            cursor: append ? this.historyCursor : ''
+        });
+    }
+
+    refreshHistory() {
+        // Перечитываем столько задач, сколько уже показано
+        this.historyAppend = false;
+        this.sendCommand('list_tasks', {
+            ...this.getHistoryFilter(),
+            limit: Math.min(Math.max(this.history.length, 50), 500)
+        });
+    }
+
+    async loadStats() {
+        try {
+            const response = await fetch('/api/stats?days=30');
+            const data = await response.json();
+            if (!response.ok) {
+                throw new Error(data.error || response.statusText);
+            }
+            this.renderStats(data);
+        } catch (error) {
+            this.addLog(`Ошибка загрузки статистики: ${error.message}`, 'error');
+        }
+    }
+
+    renderStats(report) {
+        const windowNames = { '24h': '24 часа', '7d': '7 дней', '30d': '30 дней', 'all': 'Все время' };
+        document.getElementById('stats-windows').innerHTML = report.windows.map(window => `
+            <tr>
+                <td>${windowNames[window.window] || window.window}</td>
+                <td>${window.completed}</td>
+                <td>${window.failed} (${(window.failureRate * 100).toFixed(1)}%)</td>
+                <td>${window.audioHours.toFixed(1)}</td>
+                <td>${window.averageSpeed ? window.averageSpeed.toFixed(1) + 'x' : '-'}</td>
+                <td>${this.formatFileSize(window.sourceBytes)} / ${this.formatFileSize(window.outputBytes)}</td>
+            </tr>
+        `).join('');
+
+        const errorNames = {
+            cancelled: 'отменено',
+            disk_space: 'нет места',
+            verification: 'проверка результата',
+            ffprobe: 'ffprobe',
+            ffmpeg: 'FFmpeg',
+            finalize: 'перенос файлов',
+            other: 'прочие'
+        };
+        const month = report.windows.find(window => window.window === '30d');
+        document.getElementById('stats-errors').textContent = month && month.errors.length > 0
+            ? 'Ошибки за 30 дней: ' + month.errors.map(e => `${errorNames[e.type] || e.type} - ${e.count}`).join(', ')
+            : '';
+
+        // Столбики по дням: зеленый - завершенные, красный - ошибки
+        const maxPerDay = Math.max(1, ...report.days.map(day => day.completed + day.failed));
+        document.getElementById('stats-days').innerHTML = report.days.map(day => `
+            <div class="stats-day" title="${day.date}: завершено ${day.completed}, ошибок ${day.failed}">
+                <div class="stats-day-completed" style="height: ${day.completed / maxPerDay * 100}%"></div>
+                <div class="stats-day-failed" style="height: ${day.failed / maxPerDay * 100}%"></div>
+            </div>
+        `).join('');
+    }
+
+    // Заголовок авторизации с ключом adminToken для запросов /api/history/archive и /api/bundle
+    adminHeaders() {
+        const token = document.getElementById('admin-token-input').value;
+        return token ? { 'Authorization': 'Basic ' + btoa(':' + token) } : {};
+    }
+
+    // downloadFile скачивает ответ служебного запроса как файл, имя берется из Content-Disposition
+    async downloadFile(url, fallbackName) {
+        const response = await fetch(url, { headers: this.adminHeaders() });
+        if (!response.ok) {
+            const data = await response.json().catch(() => ({}));
+            throw new Error(data.error || response.statusText);
+        }
+
+        const disposition = response.headers.get('Content-Disposition') || '';
+        const match = disposition.match(/filename="([^"]+)"/);
+        const objectURL = URL.createObjectURL(await response.blob());
+        const link = document.createElement('a');
+        link.href = objectURL;
+        link.download = match ? match[1] : fallbackName;
+        link.click();
+        URL.revokeObjectURL(objectURL);
+    }
+
+    async downloadArchive() {
+        try {
+            await this.downloadFile('/api/history/archive', 'tasks-archive.jsonl.gz');
+        } catch (error) {
+            this.addLog(`Ошибка выгрузки архива: ${error.message}`, 'error');
+        }
+    }
+
+    async exportBundle() {
+        const params = new URLSearchParams();
+        if (document.getElementById('import-config-checkbox').checked) {
+            params.set('config', '1');
+        }
+
+        try {
+            await this.downloadFile(`/api/bundle/export?${params}`, 'dts-fix-bundle.ndjson');
+        } catch (error) {
+            this.addLog(`Ошибка экспорта: ${error.message}`, 'error');
+        }
+    }
+
+    async importBundle() {
+        const file = document.getElementById('import-file-input').files[0];
+        if (!file) {
+            alert('Выберите файл выгрузки');
+            return;
+        }
+
+        const params = new URLSearchParams();
+        document.getElementById('import-remap-input').value.split(';')
+            .map(value => value.trim())
+            .filter(value => value)
+            .forEach(value => params.append('remap', value));
+        params.set('conflict', document.getElementById('import-conflict-select').value);
+        if (document.getElementById('import-config-checkbox').checked) {
+            params.set('config', '1');
+        }
+
+        try {
+            // Сначала пробный импорт, чтобы показать, что будет сделано
+            params.set('dryRun', '1');
+            const preview = await this.postBundle(file, params);
+            if (!confirm(this.formatImportSummary(preview) + '\n\nИмпортировать?')) {
+                return;
+            }
+
+            params.delete('dryRun');
+            const summary = await this.postBundle(file, params);
+            this.addLog(this.formatImportSummary(summary).replace(/\n/g, '; '), 'info');
+            this.loadState();
+        } catch (error) {
+            this.addLog(`Ошибка импорта: ${error.message}`, 'error');
+        }
+    }
+
+    async postBundle(file, params) {
+        const response = await fetch(`/api/bundle/import?${params}`, {
+            method: 'POST',
+            headers: this.adminHeaders(),
+            body: file
+        });
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || response.statusText);
+        }
+        return data;
+    }
+
+    formatImportSummary(summary) {
+        const tasks = summary.tasks;
+        const lines = [
+            `Задачи: ${tasks.total}, новых ${tasks.created}, заменено ${tasks.overwritten || 0}, ` +
+                `с новым идентификатором ${tasks.renamed || 0}, пропущено ${tasks.skipped}`,
+            `Архив: ${summary.archived.total}, новых ${summary.archived.created}, пропущено ${summary.archived.skipped}`,
+            `Пути заменены у задач: ${summary.remapped}, нет исходного файла: ${summary.missingFiles}`
+        ];
+        if (summary.config) {
+            if (summary.config.error) {
+                lines.push(`Настройки: ${summary.config.error}`);
+            } else if (summary.config.applied) {
+                lines.push('Настройки заменены, нужен перезапуск');
+            }
+        }
+        (summary.warnings || []).forEach(warning => lines.push(warning));
+        return lines.join('\n');
+    }
+
+    handlePurgeHistoryResponse(response) {
+        if (response.error) {
+            this.addLog(`Ошибка очистки истории: ${response.error}`, 'error');
+            return;
+        }
+
+        const result = response.data;
+        if (result.dryRun) {
+            if (result.matched === 0) {
+                this.addLog('Нет задач для очистки по текущему фильтру', 'info');
+                return;
+            }
+            if (confirm(`Перенести в архив задач из истории: ${result.matched}?`)) {
+                this.sendCommand('purge_history', this.getHistoryFilter());
+            }
+            return;
+        }
+
+        this.loadHistory(false);
+    }
+
+    handleListTasksResponse(response) {
+        if (response.error) {
+            this.addLog(`Ошибка загрузки истории: ${response.error}`, 'error');
+            return;
+        }
+
+        const page = response.data;
+        this.historyTotal = page.total;
+        this.historyCursor = page.nextCursor || '';
+        this.updateHistory(this.historyAppend ? this.history.concat(page.tasks) : page.tasks);
+        this.historyAppend = false;
+    }
+
     searchFiles() {
         const filePathInput = document.getElementById('file-path-input');
         const pattern = filePathInput.value.trim();
@@ -144,24 +516,83 @@ class DTSConverterApp {
             return;
         }
 
-        this.searchResults = response.data.files || [];
+        // Результаты придут сообщениями search_progress
+        this.searchId = response.data.searchId;
+        this.searchRunning = true;
+        this.searchResults = [];
         this.renderSearchResults();
-        this.addLog(`Найдено файлов: ${response.data.count}`, 'info');
     }
 
-    renderSearchResults() {
+    handleSearchProgress(data) {
+        // Порции отмененного или чужого поиска не показываем
+        if (data.searchId !== this.searchId) {
+            return;
+        }
+
+        this.searchResults = this.searchResults.concat(data.files || []);
+
+        if (data.completed) {
+            this.searchRunning = false;
+            if (data.error) {
+                this.addLog(`Ошибка поиска: ${data.error}`, 'error');
+            }
+            if (data.cancelled) {
+                this.addLog(`Поиск отменен, найдено файлов: ${data.found}`, 'warning');
+            } else if (data.truncated) {
+                this.addLog(`Найдено файлов: ${data.found} (достигнуто ограничение, уточните шаблон)`, 'warning');
+            } else {
+                this.addLog(`Найдено файлов: ${data.found}`, 'info');
+            }
+        }
+
+        this.renderSearchResults(data);
+    }
+
+    updateScanProgress(data) {
+        const status = document.getElementById('scan-status');
+        status.textContent = data.completed ? data.message : `${data.message} (${data.progress}%)`;
+        if (data.completed) {
+            this.addLog(data.message, 'info');
+        }
+    }
+
+    handleInventoryResponse(response) {
+        if (response.error) {
+            this.addLog(`Ошибка: ${response.error}`, 'error');
+            return;
+        }
+
+        if (!response.data.scan || !response.data.scan.finishedAt) {
+            this.addLog('Медиатека еще не сканировалась', 'warning');
+        }
+
+        // Файлы описи показываются в той же таблице, что и результаты поиска
+        this.cancelSearch();
+        this.searchResults = (response.data.files || []).map(file => ({
+            path: file.path,
+            name: file.path.split('/').pop(),
+            size: file.size,
+            modified: Math.floor(new Date(file.modTime).getTime() / 1000)
+        }));
+        this.renderSearchResults();
+        this.addLog(`Найдено файлов в описи: ${response.data.total}`, 'info');
+    }
+
+    renderSearchResults(progress = null) {
         const container = document.getElementById('search-results-container');
         const tbody = document.getElementById('search-results-body');
         const countSpan = document.getElementById('search-count');
         
-        if (this.searchResults.length === 0) {
+        if (this.searchResults.length === 0 && !this.searchRunning) {
             container.style.display = 'none';
             this.addLog('Файлы не найдены', 'warning');
             return;
         }
 
         container.style.display = 'block';
-        countSpan.textContent = this.searchResults.length;
+        countSpan.textContent = this.searchRunning
+            ? `${this.searchResults.length}, идет поиск... просмотрено ${progress ? progress.scannedFiles : 0} файлов`
+            : this.searchResults.length;
         
         tbody.innerHTML = this.searchResults.map((file, index) => {
             const size = this.formatFileSize(file.size);
@@ -189,15 +620,163 @@ class DTSConverterApp {
         this.sendCommand('add_task', { filePath: file.path });
     }
 
-    handleAddTaskResponse(response) {
+    browseDirectory(path) {
+        this.sendCommand('list_directory', { path: path });
+    }
+
+    handleDirectoryResponse(response) {
         if (response.error) {
+            this.addLog(`Ошибка обзора: ${response.error}`, 'error');
+            return;
+        }
+
+        this.browser = response.data;
+        this.renderBrowser();
+    }
+
+    renderBrowser() {
+        const container = document.getElementById('browser-container');
+        const tbody = document.getElementById('browser-body');
+        const listing = this.browser;
+
+        container.style.display = 'block';
+        document.getElementById('browser-path').textContent = listing.path || 'медиатеки';
+        document.getElementById('browser-enqueue-btn').style.display = listing.path ? '' : 'none';
+
+        const rows = [];
+        if (listing.path) {
+            // Из корня медиатеки возвращаемся к списку медиатек
+            rows.push(`
+                <tr class="browser-dir-row" onclick="app.browseParent()">
+                    <td class="file-name-cell">..</td><td></td><td></td><td></td>
+                </tr>
+            `);
+        }
+
+        listing.dirs.forEach((dir, index) => {
+            rows.push(`
+                <tr class="browser-dir-row" onclick="app.openBrowserDir(${index})">
+                    <td class="file-name-cell">📁 ${dir.name}</td>
+                    <td></td>
+                    <td></td>
+                    <td class="file-date-cell">${new Date(dir.modified * 1000).toLocaleString()}</td>
+                </tr>
+            `);
+        });
+
+        listing.files.forEach((file, index) => {
+            rows.push(`
+                <tr onclick="app.addFileFromBrowser(${index})">
+                    <td class="file-name-cell" title="${file.path}">${file.name}</td>
+                    <td>${this.formatAudioSummary(file)}</td>
+                    <td class="file-size-cell">${this.formatFileSize(file.size)}</td>
+                    <td class="file-date-cell">${new Date(file.modified * 1000).toLocaleString()}</td>
+                </tr>
+            `);
+        });
+
+        tbody.innerHTML = rows.join('');
+    }
+
+    formatAudioSummary(file) {
+        // Аудио известно только для файлов, уже прочитанных сканированием или при добавлении
+        if (!file.audio) {
+            return '';
+        }
+        const parts = [file.audio.profile || file.audio.codecName, file.audio.channelLayout].filter(Boolean);
+        if (file.audioStreams > 1) {
+            parts.push(`+${file.audioStreams - 1}`);
+        }
+        return parts.join(' ');
+    }
+
+    browseParent() {
+        this.browseDirectory(this.browser ? this.browser.parent || '' : '');
+    }
+
+    openBrowserDir(index) {
+        const dir = this.browser && this.browser.dirs[index];
+        if (dir) {
+            this.browseDirectory(dir.path);
+        }
+    }
+
+    addFileFromBrowser(index) {
+        const file = this.browser && this.browser.files[index];
+        if (!file) {
+            return;
+        }
+
+        this.addLog(`Добавление в очередь: ${file.name}`, 'info');
+        this.sendCommand('add_task', { filePath: file.path });
+    }
+
+    enqueueBrowsedDirectory() {
+        if (!this.browser || !this.browser.path) {
+            return;
+        }
+        this.addLog(`Добавление папки в очередь: ${this.browser.path}`, 'info');
+        this.sendCommand('add_tasks', { directory: this.browser.path, recursive: true });
+    }
+
+    addAllSearchResults() {
+        if (this.searchResults.length === 0) {
+            return;
+        }
+        this.addLog(`Добавление в очередь найденных файлов: ${this.searchResults.length}`, 'info');
+        this.sendCommand('add_tasks', { filePaths: this.searchResults.map(file => file.path) });
+    }
+
+    handleAddTasksResponse(response) {
+        if (response.error) {
+            this.addLog(`Ошибка: ${response.error}`, 'error');
+            return;
+        }
+
+        const data = response.data;
+        if (data.results.length === 0) {
+            this.addLog('Видеофайлы не найдены', 'warning');
+            return;
+        }
+        data.results
+            .filter(result => result.status !== 'added')
+            .forEach(result => this.addLog(`Пропущен ${result.filePath}: ${result.reason}`, 'warning'));
+        if (data.truncated) {
+            this.addLog('Файлов больше ограничения, добавлена только часть', 'warning');
+        }
+        this.addLog(`Добавлено в очередь: ${data.added}, пропущено: ${data.skipped}`, 'info');
+    }
+
+    closeBrowser() {
+        document.getElementById('browser-container').style.display = 'none';
+        this.browser = null;
+    }
+
+    handleAddTaskResponse(response) {
+        const duplicate = response.data && response.data.duplicate;
+        if (duplicate && !duplicate.active) {
+            // Файл уже конвертировался - повторяем только с явного согласия
+            this.addLog(response.error, 'warning');
+            if (confirm(`${response.error}. Конвертировать повторно?`)) {
+                this.sendCommand('add_task', { filePath: response.data.filePath, force: true });
+            }
+        } else if (response.error) {
             this.addLog(`Ошибка: ${response.error}`, 'error');
         } else {
             this.addLog(`Файл добавлен в очередь`, 'info');
         }
     }
 
+    cancelSearch() {
+        if (this.searchRunning) {
+            this.sendCommand('cancel_search', { searchId: this.searchId });
+        }
+        this.searchRunning = false;
+        this.searchId = null;
+    }
+
     closeSearchResults() {
+        this.cancelSearch();
         const container = document.getElementById('search-results-container');
         container.style.display = 'none';
         this.searchResults = [];
@@ -268,11 +847,18 @@ class DTSConverterApp {
             `;
         }
         
+        const pauseButton = this.activeTask.status === 'paused'
+            ? `<button class="btn btn-primary btn-small" onclick="app.resumeTask('${this.activeTask.id}')">Продолжить</button>`
+            : `<button class="btn btn-secondary btn-small" onclick="app.pauseTask('${this.activeTask.id}')">Пауза</button>`;
+
         currentFile.innerHTML = `
             <div class="current-file-card">
                 <div class="current-file-header">
                     <div class="current-file-name">${this.getFileName(this.activeTask.filePath)}</div>
-                    <button class="btn btn-danger btn-small" onclick="app.cancelTask('${this.activeTask.id}')">Отменить</button>
+                    <div class="current-file-actions">
+                        ${pauseButton}
+                        <button class="btn btn-danger btn-small" onclick="app.cancelTask('${this.activeTask.id}')">Отменить</button>
+                    </div>
                 </div>
                 <div class="current-file-path">${this.activeTask.filePath}</div>
                 <div class="current-file-info">
@@ -317,14 +903,24 @@ class DTSConverterApp {
                 `;
             }
             
-            const deleteButton = `<button class="btn btn-danger btn-small" onclick="app.deleteTask('${item.id}', ${item.status === 'processing'})">Удалить</button>`;
+            const isRunning = item.status === 'processing' || item.status === 'paused';
+            const deleteButton = `<button class="btn btn-danger btn-small" onclick="app.deleteTask('${item.id}', ${isRunning})">Удалить</button>`;
             
+            const sizeHtml = item.estimatedSize
+                ? `<div class="queue-item-path">Оценка результата: ${this.formatFileSize(item.estimatedSize)}</div>`
+                : '';
+            const holdHtml = item.holdReason
+                ? `<div class="history-item-error-msg">${item.holdReason}</div>`
+                : '';
+
             return `
                 <div class="queue-item">
                     <div class="queue-item-info">
                         <div class="queue-item-name">${this.getFileName(item.filePath)}</div>
                         <div class="queue-item-path">${item.filePath}</div>
                         ${audioInfoHtml}
+                        ${sizeHtml}
+                        ${holdHtml}
                     </div>
                     <div class="queue-item-actions">
                         <div class="queue-item-status status-${item.status}">
@@ -344,6 +940,8 @@ class DTSConverterApp {
 
     renderHistory() {
         const historyList = document.getElementById('history-list');
+        document.getElementById('history-total').textContent = this.historyTotal;
+        document.getElementById('history-more-btn').style.display = this.historyCursor ? 'block' : 'none';
         
         if (this.history.length === 0) {
             historyList.innerHTML = '<div class="empty-history">История пуста</div>';
@@ -365,6 +963,11 @@ class DTSConverterApp {
                 `;
             }
             
+            const canRevert = item.status === 'completed' && item.finalize !== 'replace';
+            const revertButton = canRevert
+                ? `<button class="btn btn-secondary btn-small" onclick="app.revertTask('${item.id}')">Откатить</button>`
+                : '';
+
             return `
                 <div class="history-item ${hasError ? 'history-item-error' : ''}">
                     <div class="history-item-info">
@@ -378,6 +981,7 @@ class DTSConverterApp {
                         <div class="history-item-status status-${item.status}">
                             ${this.getStatusText(item.status)}
                         </div>
+                        ${revertButton}
                     </div>
                 </div>
             `;
@@ -441,6 +1045,59 @@ class DTSConverterApp {
         }
     }
 
+    revertTask(taskId) {
+        if (!confirm('Вернуть исходный файл и удалить результат конвертации?')) {
+            return;
+        }
+
+        this.sendCommand('revert_task', { taskId: taskId });
+    }
+
+    pauseTask(taskId) {
+        this.sendCommand('pause_task', { taskId: taskId });
+    }
+
+    resumeTask(taskId) {
+        this.sendCommand('resume_task', { taskId: taskId });
+    }
+
+    toggleQueuePause() {
+        this.sendCommand(this.queuePaused ? 'resume_queue' : 'pause_queue');
+    }
+
+    updateQueuePaused(paused) {
+        this.queuePaused = !!paused;
+        const button = document.getElementById('queue-pause-btn');
+        button.textContent = this.queuePaused ? 'Возобновить очередь' : 'Приостановить очередь';
+        button.className = this.queuePaused ? 'btn btn-primary btn-small' : 'btn btn-secondary btn-small';
+    }
+
+    updateSchedule(schedule) {
+        const item = document.getElementById('schedule-status-item');
+        const element = document.getElementById('schedule-status');
+
+        if (!schedule || !schedule.enabled) {
+            item.style.display = 'none';
+            return;
+        }
+
+        item.style.display = '';
+        if (schedule.open) {
+            element.textContent = 'Окно открыто';
+            element.className = 'status-online';
+        } else {
+            const next = schedule.nextWindowStart ? new Date(schedule.nextWindowStart).toLocaleString() : 'N/A';
+            element.textContent = `Ожидание окна (${next})`;
+            element.className = 'status-offline';
+        }
+    }
+
+    handleCommandResponse(response) {
+        if (response.error) {
+            this.addLog(`Ошибка: ${response.error}`, 'error');
+        }
+    }
+
     deleteTask(taskId, isProcessing = false) {
         let confirmMessage = 'Вы уверены, что хотите удалить задачу из очереди?';
         
@@ -474,8 +1131,11 @@ class DTSConverterApp {
         const statusMap = {
             'pending': 'Ожидание',
             'processing': 'В процессе',
+            'paused': 'Пауза',
             'completed': 'Завершено',
-            'error': 'Ошибка'
+            'error': 'Ошибка',
+            'skipped': 'Пропущено',
+            'reverted': 'Откачено'
         };
         return statusMap[status] || status;
     }